@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/joho/godotenv"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"gorm.io/gorm/logger"
+)
+
+// backupDir is the directory backups are kept in when BACKUP_DIR is not set
+const backupDir = "database/backups"
+
+// backup runs the backup command with the given arguments:
+// backup [path]
+// it backs up the running or stopped database to the path, or into the backup directory
+func backup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// The .env file is optional here, DATABASE_URL can be set in the environment directly
+	godotenv.Load()
+
+	driver, dsn, err := databaseDSN()
+	if err != nil {
+		return err
+	}
+
+	db := database.Open(driver, dsn, logger.Discard)
+	if path := flags.Arg(0); path != "" {
+		if err := database.Backup(context.Background(), db, path); err != nil {
+			return err
+		}
+		fmt.Printf("Backed up the database to %s\n", path)
+		return nil
+	}
+
+	path, err := newBackups(db).BackupOnce(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up the database to %s\n", path)
+	return nil
+}
+
+// restore runs the restore command with the given arguments:
+// restore <path>
+// it replaces the database with the backup at path after checking its schema version, the bot must be stopped
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	path := flags.Arg(0)
+	if path == "" {
+		return fmt.Errorf("usage: restore <path>")
+	}
+
+	godotenv.Load()
+
+	driver, dsn, err := databaseDSN()
+	if err != nil {
+		return err
+	}
+	if driver != database.SQLite {
+		return database.ErrBackupUnsupported
+	}
+
+	version, previous, err := database.Restore(path, dsn, database.Migrations)
+	if err != nil {
+		return err
+	}
+
+	if previous == "" {
+		fmt.Printf("Restored %s at version %d\n", path, version)
+		return nil
+	}
+	fmt.Printf("Restored %s at version %d, the previous database is at %s\n", path, version, previous)
+	return nil
+}
@@ -6,12 +6,14 @@ import (
 	"strings"
 
 	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/locale"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 type Sender interface {
 	Send(tgbotapi.Chattable) (tgbotapi.Message, error)
+	AnswerCallbackQuery(tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error)
 }
 
 // HandlePayload wraps update and bot for convenience
@@ -21,13 +23,24 @@ type HandlePayload struct {
 	User   database.User
 }
 
-func (p HandlePayload) Respond(text string) {
-	var chatID int64
+// ChatID returns the id of the chat the update came from, 0 if there is none
+func (p HandlePayload) ChatID() int64 {
 	if p.Update.Message != nil {
-		chatID = p.Update.Message.Chat.ID
+		return p.Update.Message.Chat.ID
 	} else if p.Update.CallbackQuery != nil {
-		chatID = p.Update.CallbackQuery.Message.Chat.ID
-	} else {
+		return p.Update.CallbackQuery.Message.Chat.ID
+	}
+	return 0
+}
+
+// T translates the dutch text to the language of the user
+func (p HandlePayload) T(text string) string {
+	return locale.T(p.User.Settings.Language, text)
+}
+
+func (p HandlePayload) Respond(text string) {
+	chatID := p.ChatID()
+	if chatID == 0 {
 		return
 	}
 
@@ -35,6 +48,18 @@ func (p HandlePayload) Respond(text string) {
 	p.Bot.Send(msg)
 }
 
+// Answer answers the callback query of the update, showing the text as a toast when it is not empty
+// Telegram shows the button as loading until its callback query is answered
+func (p HandlePayload) Answer(text string) {
+	if p.Update.CallbackQuery == nil {
+		return
+	}
+
+	if _, err := p.Bot.AnswerCallbackQuery(tgbotapi.NewCallback(p.Update.CallbackQuery.ID, text)); err != nil {
+		log.Printf("ERROR: Error answering callback query %s, err: %+v", p.Update.CallbackQuery.ID, err)
+	}
+}
+
 // Handler is the interface used to handle bot updates
 type Handler interface {
 	isMatch(payload *HandlePayload) bool
@@ -64,6 +89,21 @@ func (c *CommandHandler) handle(p *HandlePayload) {
 	c.Handler(p, parseArgs(p.Update.Message.Text))
 }
 
+// CallbackHandler listens for inline keyboard callbacks with data in the format {Prefix}|{data}
+type CallbackHandler struct {
+	Prefix  string
+	Handler func(payload *HandlePayload, data string)
+}
+
+// isMatch determines if this callback should be handled by this handler
+func (c *CallbackHandler) isMatch(p *HandlePayload) bool {
+	return p.Update.CallbackQuery != nil && strings.HasPrefix(p.Update.CallbackQuery.Data, c.Prefix+"|")
+}
+
+func (c *CallbackHandler) handle(p *HandlePayload) {
+	c.Handler(p, strings.TrimPrefix(p.Update.CallbackQuery.Data, c.Prefix+"|"))
+}
+
 // Middleware is ran on every request, the handler must only change the handlepayload when IsSync is true
 // If IsSync is false and the handlepayload is changed the handler will probably not get the updated values
 type Middleware struct {
@@ -85,12 +125,7 @@ func Start(middleware []Middleware, handlers []Handler) *tgbotapi.BotAPI {
 
 	log.Printf("Bot %s authorized\n", bot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates, err := bot.GetUpdatesChan(u)
-	if err != nil {
-		log.Panic(err)
-	}
+	updates := receiveUpdates(bot)
 
 	syncMiddleware, asyncMiddleware := splitMiddleware(middleware)
 
@@ -103,6 +138,40 @@ func Start(middleware []Middleware, handlers []Handler) *tgbotapi.BotAPI {
 	return bot
 }
 
+// receiveUpdates returns the updates of the bot, received over the webhook or polled for depending on BOT_MODE
+// The webhook is registered with telegram in webhook mode and removed in polling mode, telegram does not allow both
+func receiveUpdates(bot *tgbotapi.BotAPI) tgbotapi.UpdatesChannel {
+	switch mode := os.Getenv("BOT_MODE"); mode {
+	case "webhook":
+		config, err := webhookConfigFromEnv()
+		if err != nil {
+			log.Panicf("ERROR: %+v", err)
+		}
+
+		if err := setWebhook(bot, config); err != nil {
+			log.Panicf("ERROR: can't register webhook: %+v", err)
+		}
+		log.Printf("Registered webhook %s", config.URL)
+
+		return listenForWebhook(config)
+	case "", "polling":
+		if _, err := bot.RemoveWebhook(); err != nil {
+			log.Panicf("ERROR: can't remove webhook: %+v", err)
+		}
+
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		updates, err := bot.GetUpdatesChan(u)
+		if err != nil {
+			log.Panic(err)
+		}
+		return updates
+	default:
+		log.Panicf("ERROR: BOT_MODE environment variable must be polling or webhook, got %q", mode)
+		return nil
+	}
+}
+
 func handle(update tgbotapi.Update, sender Sender, syncMiddleware []Middleware, asyncMiddleware []Middleware, handlers []Handler) {
 	if update.Message == nil && update.CallbackQuery == nil {
 		return
@@ -21,6 +21,10 @@ func (m mockSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return tgbotapi.Message{}, nil
 }
 
+func (m mockSender) AnswerCallbackQuery(c tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
 func newMockCommandUpdate(command string, args string) tgbotapi.Update {
 	return tgbotapi.Update{
 		Message: &tgbotapi.Message{
@@ -71,6 +75,45 @@ func TestCommandHandler(t *testing.T) {
 	}
 }
 
+func TestCallbackHandler(t *testing.T) {
+	failTimer := time.NewTimer(time.Second)
+	done := make(chan string)
+
+	sender := mockSender{}
+	middlewares := make([]Middleware, 0)
+	handlers := []Handler{
+		&CallbackHandler{
+			Prefix: "other",
+			Handler: func(p *HandlePayload, data string) {
+				t.Error("Handled by the wrong callback handler")
+			},
+		},
+		&CallbackHandler{
+			Prefix: "test",
+			Handler: func(p *HandlePayload, data string) {
+				done <- data
+			},
+		},
+	}
+
+	update := tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			Data: "test|123",
+		},
+	}
+
+	handle(update, sender, middlewares, middlewares, handlers)
+
+	select {
+	case <-failTimer.C:
+		t.Error("Did not handle test callback in 1 second")
+	case data := <-done:
+		if data != "123" {
+			t.Errorf("Expected data 123, got %s", data)
+		}
+	}
+}
+
 func TestConversationHandler(t *testing.T) {
 	failTimer := time.NewTimer(time.Second * 1)
 	done := make(chan bool)
@@ -64,7 +64,7 @@ func (c *conversationHandler) handle(p *HandlePayload) {
 	// TODO: onStop hook?
 	if p.Update.Message != nil && p.Update.Message.IsCommand() && p.Update.Message.Command() == "stop" {
 		c.instances.Delete(p.User.ID)
-		p.Respond("Gestopt")
+		p.Respond(p.T("Gestopt"))
 		return
 	}
 
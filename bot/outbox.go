@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/locale"
+)
+
+const (
+	// outboxInterval is how often the outbox is checked for messages to send
+	outboxInterval = time.Second * 5
+	// outboxBatch is the maximum amount of messages sent every interval
+	outboxBatch = 50
+	// outboxBaseDelay is the delay before the first retry, it doubles every attempt
+	outboxBaseDelay = time.Second * 10
+	// outboxMaxDelay caps the delay between attempts
+	outboxMaxDelay = time.Hour
+)
+
+// OutboxWorker sends the messages in the outbox until telegram accepts them
+type OutboxWorker struct {
+	outbox database.OutboxRepository
+	sender Sender
+	// now returns the current time, overridden in tests
+	now func() time.Time
+}
+
+// NewOutboxWorker returns a worker that sends the messages in the outbox with sender
+func NewOutboxWorker(outbox database.OutboxRepository, sender Sender) *OutboxWorker {
+	return &OutboxWorker{outbox: outbox, sender: sender, now: time.Now}
+}
+
+// Run delivers the outbox every interval until the context is done
+func (w *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.DeliverOnce(ctx); err != nil {
+				log.Printf("ERROR: Error delivering outbox, err: %+v", err)
+			}
+		}
+	}
+}
+
+// DeliverOnce sends the pending messages, failed messages are scheduled again with exponential backoff
+func (w *OutboxWorker) DeliverOnce(ctx context.Context) error {
+	now := w.now()
+	messages, err := w.outbox.Pending(ctx, now, outboxBatch)
+	if err != nil {
+		return fmt.Errorf("can't load pending messages: %w", err)
+	}
+
+	for _, message := range messages {
+		if _, err := w.sender.Send(outboxChattable(message)); err != nil {
+			next := now.Add(outboxBackoff(message.Attempts))
+			log.Printf("WARNING: Error sending message %d to chat %d, attempt %d, retrying at %s: %+v", message.ID, message.ChatID, message.Attempts+1, next, err)
+
+			if err := w.outbox.MarkFailed(ctx, &message, err, next); err != nil {
+				log.Printf("ERROR: Error marking message %d as failed: %+v", message.ID, err)
+			}
+			continue
+		}
+
+		if err := w.outbox.MarkDelivered(ctx, &message, now); err != nil {
+			log.Printf("ERROR: Error marking message %d as delivered: %+v", message.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// outboxBackoff returns the delay before the next attempt after the given amount of failed attempts
+func outboxBackoff(attempts uint) time.Duration {
+	delay := float64(outboxBaseDelay) * math.Pow(2, float64(attempts))
+	if delay > float64(outboxMaxDelay) {
+		return outboxMaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// outboxChattable converts the message to a telegram message
+func outboxChattable(message database.OutboxMessage) tgbotapi.Chattable {
+	msg := tgbotapi.NewMessage(message.ChatID, message.Text)
+	msg.DisableNotification = message.Silent
+	if message.BookLessonID != "" {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(locale.T(message.Language, "Boek nu"), fmt.Sprintf("book|%s", message.BookLessonID)),
+			),
+		)
+	}
+	return msg
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/database/databasetest"
+	"gorm.io/gorm"
+)
+
+type failingSender struct {
+	failures int
+	sent     []tgbotapi.MessageConfig
+}
+
+func (f *failingSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if f.failures > 0 {
+		f.failures--
+		return tgbotapi.Message{}, errors.New("telegram down")
+	}
+
+	f.sent = append(f.sent, c.(tgbotapi.MessageConfig))
+	return tgbotapi.Message{}, nil
+}
+
+func (f *failingSender) AnswerCallbackQuery(c tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func TestOutboxWorker(t *testing.T) {
+	databasetest.Run(t, testOutboxWorker)
+}
+
+func testOutboxWorker(t *testing.T, db *gorm.DB) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	db.Create(&database.OutboxMessage{ChatID: 1, Text: "Plek vrij", BookLessonID: "123"})
+
+	sender := &failingSender{failures: 2}
+	worker := NewOutboxWorker(database.NewRepositories(db).Outbox, sender)
+	worker.now = func() time.Time {
+		return now
+	}
+
+	// The first attempt fails and is retried after the base delay
+	worker.DeliverOnce(context.Background())
+	worker.DeliverOnce(context.Background())
+	if len(sender.sent) != 0 || sender.failures != 1 {
+		t.Fatalf("Expected one attempt before the retry delay, %d failures left", sender.failures)
+	}
+
+	// The second attempt fails and is retried after double the delay
+	now = now.Add(outboxBaseDelay)
+	worker.DeliverOnce(context.Background())
+	now = now.Add(outboxBaseDelay)
+	worker.DeliverOnce(context.Background())
+	if len(sender.sent) != 0 {
+		t.Fatal("Expected the retry delay to double")
+	}
+
+	now = now.Add(outboxBaseDelay)
+	worker.DeliverOnce(context.Background())
+	if len(sender.sent) != 1 {
+		t.Fatalf("Expected the message to be sent, got %d", len(sender.sent))
+	}
+
+	if sender.sent[0].ChatID != 1 || sender.sent[0].Text != "Plek vrij" || sender.sent[0].ReplyMarkup == nil {
+		t.Errorf("Sent wrong message %+v", sender.sent[0])
+	}
+
+	// Delivered messages are never sent again
+	now = now.Add(outboxMaxDelay)
+	worker.DeliverOnce(context.Background())
+	if len(sender.sent) != 1 {
+		t.Errorf("Expected the message to be sent once, got %d", len(sender.sent))
+	}
+
+	message := database.OutboxMessage{}
+	db.First(&message)
+	if message.DeliveredAt == nil || message.Attempts != 2 || message.LastError != "telegram down" {
+		t.Errorf("Expected a delivered message after 2 attempts, got %+v", message)
+	}
+}
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// secretHeader is the header telegram sends the secret token of the webhook in
+const secretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// validSecret matches the secret tokens telegram accepts
+var validSecret = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// WebhookConfig configures receiving updates from telegram over https instead of polling for them
+type WebhookConfig struct {
+	// URL is the public https url telegram sends updates to
+	URL string
+	// Listen is the address the server listens on, like :8080
+	Listen string
+	// Path is the path the server receives updates on, it differs from the path of URL behind a proxy that rewrites it
+	Path string
+	// Secret is sent by telegram with every update so others can't send updates
+	Secret string
+}
+
+// webhookConfigFromEnv returns the webhook configured by the WEBHOOK_ environment variables
+// Listen defaults to :8080 and Path to the path of the url
+func webhookConfigFromEnv() (WebhookConfig, error) {
+	config := WebhookConfig{
+		URL:    os.Getenv("WEBHOOK_URL"),
+		Listen: os.Getenv("WEBHOOK_LISTEN"),
+		Path:   os.Getenv("WEBHOOK_PATH"),
+		Secret: os.Getenv("WEBHOOK_SECRET"),
+	}
+
+	link, err := url.Parse(config.URL)
+	if err != nil || link.Scheme != "https" || link.Host == "" {
+		return config, fmt.Errorf("WEBHOOK_URL must be an https url, got %q", config.URL)
+	}
+
+	if !validSecret.MatchString(config.Secret) {
+		return config, errors.New("WEBHOOK_SECRET must be 1 to 256 letters, numbers, _ or -")
+	}
+
+	if config.Listen == "" {
+		config.Listen = ":8080"
+	}
+
+	if config.Path == "" {
+		config.Path = link.Path
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	return config, nil
+}
+
+// setWebhook registers the webhook with telegram, the library does not support secret tokens so the request is made directly
+func setWebhook(bot *tgbotapi.BotAPI, config WebhookConfig) error {
+	params := url.Values{}
+	params.Set("url", config.URL)
+	params.Set("secret_token", config.Secret)
+
+	_, err := bot.MakeRequest("setWebhook", params)
+	return err
+}
+
+// Timeouts of the webhook server, telegram sends small updates and retries them when they are not answered
+const (
+	webhookReadHeaderTimeout = time.Second * 5
+	webhookReadTimeout       = time.Second * 10
+	webhookWriteTimeout      = time.Second * 10
+	webhookIdleTimeout       = time.Minute
+)
+
+// listenForWebhook serves the webhook and returns the channel the updates telegram sends are put on
+func listenForWebhook(config WebhookConfig) tgbotapi.UpdatesChannel {
+	updates := make(chan tgbotapi.Update, 100)
+	server := newWebhookServer(config, updates)
+
+	go func() {
+		log.Printf("Listening for updates on %s%s", config.Listen, config.Path)
+		if err := server.ListenAndServe(); err != nil {
+			log.Panicf("ERROR: webhook server stopped: %+v", err)
+		}
+	}()
+
+	return updates
+}
+
+// newWebhookServer returns the server receiving updates on the path of the webhook
+func newWebhookServer(config WebhookConfig, updates chan<- tgbotapi.Update) *http.Server {
+	return &http.Server{
+		Addr:              config.Listen,
+		Handler:           webhookHandler(config.Path, config.Secret, updates),
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+		ReadTimeout:       webhookReadTimeout,
+		WriteTimeout:      webhookWriteTimeout,
+		IdleTimeout:       webhookIdleTimeout,
+	}
+}
+
+// webhookHandler receives updates telegram posts to path and puts them on updates
+// Requests to other paths are not found and requests without the secret token are refused
+func webhookHandler(path string, secret string, updates chan<- tgbotapi.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretHeader)), []byte(secret)) != 1 {
+			log.Printf("ERROR: Refused webhook request from %s with a wrong secret token", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+			log.Printf("ERROR: Can't decode webhook update, err: %+v", err)
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		// Telegram resends updates that are not answered with a 2xx in time, so the update is queued instead of handled here
+		select {
+		case updates <- update:
+		case <-r.Context().Done():
+			http.Error(w, "timeout", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
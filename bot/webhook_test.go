@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+type webhookPayload struct {
+	method string
+	path   string
+	secret string
+	body   string
+	status int
+}
+
+func TestWebhookHandler(t *testing.T) {
+	payloads := []webhookPayload{
+		{method: http.MethodPost, path: "/telegram", secret: "secret", body: `{"update_id":1,"message":{"message_id":2,"text":"/help"}}`, status: http.StatusOK},
+		{method: http.MethodPost, path: "/telegram", secret: "wrong", body: `{"update_id":1}`, status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/telegram", secret: "", body: `{"update_id":1}`, status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/telegram", secret: "secret", body: "", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/telegram", secret: "secret", body: `{"update_id":`, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/other", secret: "secret", body: `{"update_id":1}`, status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/telegram/other", secret: "secret", body: `{"update_id":1}`, status: http.StatusNotFound},
+	}
+
+	for _, payload := range payloads {
+		updates := make(chan tgbotapi.Update, 1)
+		request := httptest.NewRequest(payload.method, payload.path, strings.NewReader(payload.body))
+		if payload.secret != "" {
+			request.Header.Set(secretHeader, payload.secret)
+		}
+		recorder := httptest.NewRecorder()
+
+		webhookHandler("/telegram", "secret", updates).ServeHTTP(recorder, request)
+
+		if recorder.Code != payload.status {
+			t.Errorf("Expected status %d for %s %s with secret %q and body %q, got %d", payload.status, payload.method, payload.path, payload.secret, payload.body, recorder.Code)
+		}
+
+		if payload.status != http.StatusOK {
+			if len(updates) != 0 {
+				t.Errorf("Expected no update for %s with secret %q and body %q", payload.method, payload.secret, payload.body)
+			}
+			continue
+		}
+
+		update := <-updates
+		if update.UpdateID != 1 || update.Message == nil || update.Message.Text != "/help" {
+			t.Errorf("Expected the decoded update, got %+v", update)
+		}
+	}
+}
+
+type webhookConfigPayload struct {
+	url    string
+	path   string
+	secret string
+	valid  bool
+	result string
+}
+
+func TestWebhookConfigFromEnv(t *testing.T) {
+	payloads := []webhookConfigPayload{
+		{url: "https://bot.example.com/telegram", secret: "abc_DEF-1", valid: true, result: "/telegram"},
+		{url: "https://bot.example.com/telegram", path: "/hook", secret: "abc", valid: true, result: "/hook"},
+		{url: "https://bot.example.com", secret: "abc", valid: true, result: "/"},
+		{url: "http://bot.example.com/telegram", secret: "abc", valid: false},
+		{url: "", secret: "abc", valid: false},
+		{url: "https://bot.example.com/telegram", secret: "", valid: false},
+		{url: "https://bot.example.com/telegram", secret: "not allowed!", valid: false},
+	}
+
+	defer os.Unsetenv("WEBHOOK_URL")
+	defer os.Unsetenv("WEBHOOK_PATH")
+	defer os.Unsetenv("WEBHOOK_SECRET")
+	os.Unsetenv("WEBHOOK_LISTEN")
+
+	for _, payload := range payloads {
+		os.Setenv("WEBHOOK_URL", payload.url)
+		os.Setenv("WEBHOOK_PATH", payload.path)
+		os.Setenv("WEBHOOK_SECRET", payload.secret)
+
+		config, err := webhookConfigFromEnv()
+		if (err == nil) != payload.valid {
+			t.Errorf("Expected valid %t for url %q and secret %q, got %v", payload.valid, payload.url, payload.secret, err)
+			continue
+		}
+
+		if payload.valid && (config.Path != payload.result || config.Listen != ":8080") {
+			t.Errorf("Expected path %s on :8080 for url %q and path %q, got %s on %s", payload.result, payload.url, payload.path, config.Path, config.Listen)
+		}
+	}
+}
+
+func TestWebhookServer(t *testing.T) {
+	server := newWebhookServer(WebhookConfig{Listen: ":8080", Path: "/", Secret: "secret"}, make(chan tgbotapi.Update, 1))
+
+	if server.ReadHeaderTimeout == 0 || server.ReadTimeout == 0 || server.WriteTimeout == 0 || server.IdleTimeout == 0 {
+		t.Errorf("Expected the server to time out slow clients, got %+v", server)
+	}
+
+	// The default path only matches itself
+	request := httptest.NewRequest(http.MethodPost, "/anything", strings.NewReader(`{"update_id":1}`))
+	request.Header.Set(secretHeader, "secret")
+	recorder := httptest.NewRecorder()
+	server.Handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another path, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
@@ -0,0 +1,140 @@
+package checker
+
+import (
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+)
+
+// ChangeKind is a way a lesson changed upstream
+type ChangeKind string
+
+const (
+	// ChangeCancelled is a lesson that was cancelled
+	ChangeCancelled ChangeKind = "cancelled"
+	// ChangeMoved is a lesson with a new start or duration
+	ChangeMoved ChangeKind = "moved"
+	// ChangeInstructor is a lesson with a new instructor
+	ChangeInstructor ChangeKind = "instructor"
+	// ChangeRoom is a lesson in a new room
+	ChangeRoom ChangeKind = "room"
+	// ChangeVanished is a lesson that is not in the schedule anymore
+	ChangeVanished ChangeKind = "vanished"
+)
+
+// statusVanished is stored as the status of lessons that are not in the schedule anymore
+// The notis are kept in case the lesson comes back, for example when it moved to another day
+const statusVanished = "vanished"
+
+// Change is sent to the user of a noti whose lesson changed upstream
+type Change struct {
+	// Noti has the lesson as it was before the change
+	Noti database.Noti
+	// Lesson is the lesson as it is now
+	Lesson database.Lesson
+	Kinds  []ChangeKind
+}
+
+// Has returns if the lesson changed in the given way
+func (c Change) Has(kind ChangeKind) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Ends returns if the noti has nothing to watch anymore after the change
+func (c Change) Ends() bool {
+	return c.Has(ChangeCancelled)
+}
+
+// detectChanges compares the stored lessons of the notis with the lessons upstream
+// It returns a change for every noti whose lesson changed and the stored lessons that should be updated
+// Lessons in failed windows can't be compared and are skipped
+func detectChanges(notis []database.Noti, lessons []fitforfree.Lesson, failed []window) ([]Change, []database.Lesson) {
+	fresh := make(map[string]database.Lesson, len(lessons))
+	for _, lesson := range lessons {
+		fresh[lesson.ID] = database.NewLesson(lesson)
+	}
+
+	changes := make([]Change, 0)
+	updated := make([]database.Lesson, 0)
+	seen := make(map[string]bool)
+	for _, noti := range notis {
+		stored := noti.Lesson
+		lesson, ok := fresh[stored.ID]
+		if !ok {
+			// Only report vanishing once, and not when the lesson could not be fetched
+			if stored.Status == statusVanished || inWindows(stored, failed) {
+				continue
+			}
+
+			lesson = stored
+			lesson.Status = statusVanished
+			changes = append(changes, Change{Noti: noti, Lesson: lesson, Kinds: []ChangeKind{ChangeVanished}})
+			if !seen[stored.ID] {
+				updated = append(updated, lesson)
+			}
+			seen[stored.ID] = true
+			continue
+		}
+
+		if kinds := diffLesson(stored, lesson); len(kinds) > 0 {
+			changes = append(changes, Change{Noti: noti, Lesson: lesson, Kinds: kinds})
+		}
+
+		if !seen[stored.ID] && lessonOutdated(stored, lesson) {
+			updated = append(updated, lesson)
+		}
+		seen[stored.ID] = true
+	}
+
+	return changes, updated
+}
+
+// diffLesson returns how the lesson changed from stored to fresh
+// Fields that were not stored yet are not reported as a change
+func diffLesson(stored database.Lesson, fresh database.Lesson) []ChangeKind {
+	kinds := make([]ChangeKind, 0)
+	if fitforfree.IsCancelled(fresh.Status) && !fitforfree.IsCancelled(stored.Status) {
+		kinds = append(kinds, ChangeCancelled)
+	}
+
+	if fresh.Start != stored.Start || fresh.DurationSeconds != stored.DurationSeconds {
+		kinds = append(kinds, ChangeMoved)
+	}
+
+	if stored.Instructor != "" && fresh.Instructor != stored.Instructor {
+		kinds = append(kinds, ChangeInstructor)
+	}
+
+	if stored.RoomName != "" && fresh.RoomName != stored.RoomName {
+		kinds = append(kinds, ChangeRoom)
+	}
+
+	return kinds
+}
+
+// lessonOutdated returns if the stored lesson differs from the fresh lesson
+func lessonOutdated(stored database.Lesson, fresh database.Lesson) bool {
+	return stored.Start != fresh.Start ||
+		stored.DurationSeconds != fresh.DurationSeconds ||
+		stored.Name != fresh.Name ||
+		stored.ClassType != fresh.ClassType ||
+		stored.Status != fresh.Status ||
+		stored.Instructor != fresh.Instructor ||
+		stored.RoomName != fresh.RoomName ||
+		stored.VenueID != fresh.VenueID ||
+		stored.VenueName != fresh.VenueName
+}
+
+// inWindows returns if the lesson is in one of the windows
+func inWindows(lesson database.Lesson, windows []window) bool {
+	for _, w := range windows {
+		if lesson.Start >= w.start && lesson.Start <= w.end && w.hasVenue(lesson.VenueID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package checker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/gorm"
+)
+
+type detectChangesPayload struct {
+	stored  database.Lesson
+	fresh   *fitforfree.Lesson
+	failed  []window
+	kinds   []ChangeKind
+	updated bool
+}
+
+func TestDetectChanges(t *testing.T) {
+	stored := database.Lesson{ID: "1", Start: 100, DurationSeconds: 60, Status: "open", Instructor: "Sanne", RoomName: "Zaal 1"}
+	fresh := func(change func(*fitforfree.Lesson)) *fitforfree.Lesson {
+		lesson := &fitforfree.Lesson{ID: "1", StartTimestamp: 100, DurationSeconds: 60, Status: "open", Instructor: "Sanne", RoomName: "Zaal 1"}
+		change(lesson)
+		return lesson
+	}
+
+	payloads := []detectChangesPayload{
+		{
+			stored: stored,
+			fresh:  fresh(func(l *fitforfree.Lesson) {}),
+		},
+		{
+			stored:  stored,
+			fresh:   fresh(func(l *fitforfree.Lesson) { l.Status = fitforfree.StatusCancelled }),
+			kinds:   []ChangeKind{ChangeCancelled},
+			updated: true,
+		},
+		{
+			stored:  stored,
+			fresh:   fresh(func(l *fitforfree.Lesson) { l.StartTimestamp = 200 }),
+			kinds:   []ChangeKind{ChangeMoved},
+			updated: true,
+		},
+		{
+			stored:  stored,
+			fresh:   fresh(func(l *fitforfree.Lesson) { l.Instructor = "Joost"; l.RoomName = "Zaal 2" }),
+			kinds:   []ChangeKind{ChangeInstructor, ChangeRoom},
+			updated: true,
+		},
+		// Fields that were not stored yet are filled in without telling anyone
+		{
+			stored:  database.Lesson{ID: "1", Start: 100, DurationSeconds: 60},
+			fresh:   fresh(func(l *fitforfree.Lesson) {}),
+			updated: true,
+		},
+		{
+			stored:  stored,
+			kinds:   []ChangeKind{ChangeVanished},
+			updated: true,
+		},
+		// Lessons that could not be fetched did not vanish
+		{
+			stored: stored,
+			failed: []window{{start: 99, end: 161}},
+		},
+		// Vanishing is only reported once
+		{
+			stored: database.Lesson{ID: "1", Start: 100, Status: statusVanished},
+		},
+	}
+
+	for i, payload := range payloads {
+		lessons := []fitforfree.Lesson{}
+		if payload.fresh != nil {
+			lessons = append(lessons, *payload.fresh)
+		}
+
+		changes, updated := detectChanges([]database.Noti{{Lesson: payload.stored}}, lessons, payload.failed)
+
+		if len(payload.kinds) == 0 && len(changes) != 0 {
+			t.Errorf("Payload %d: expected no changes, got %+v", i, changes)
+		}
+
+		if len(payload.kinds) > 0 && (len(changes) != 1 || !reflect.DeepEqual(changes[0].Kinds, payload.kinds)) {
+			t.Errorf("Payload %d: expected changes %v, got %+v", i, payload.kinds, changes)
+		}
+
+		if payload.updated != (len(updated) == 1) {
+			t.Errorf("Payload %d: expected the lesson to be updated: %t, got %+v", i, payload.updated, updated)
+		}
+	}
+}
+
+func TestCheckOnceChanges(t *testing.T) {
+	lessons := fakeLessons{
+		{ID: "cancelled", StartTimestamp: 100, SpotsAvailable: 1, Status: fitforfree.StatusCancelled},
+		{ID: "moved", StartTimestamp: 250, SpotsAvailable: 1},
+	}
+
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, Lesson: database.Lesson{ID: "cancelled", Start: 100}},
+		{Model: gorm.Model{ID: 2}, Lesson: database.Lesson{ID: "moved", Start: 200}},
+		{Model: gorm.Model{ID: 3}, Lesson: database.Lesson{ID: "vanished", Start: 300}},
+	}}
+
+	c := New(Config{
+		Lessons: lessons,
+		Notis:   notis,
+		Format:  formatLessonID{},
+	})
+	c.now = func() time.Time {
+		return time.Unix(0, 0)
+	}
+
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	texts := make([]string, 0)
+	for _, h := range notis.handled {
+		texts = append(texts, h.Message.Text)
+	}
+
+	// The cancelled lesson is not notified as available, the moved lesson is notified with its new start
+	expected := []string{"cancelled [cancelled]", "moved [moved]", "vanished [vanished]", "moved"}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Fatalf("Expected messages %v, got %v", expected, texts)
+	}
+
+	if !notis.handled[0].Retire || notis.handled[1].Retire || notis.handled[2].Retire {
+		t.Error("Only the noti of the cancelled lesson should be retired")
+	}
+
+	if notis.handled[3].Noti.Lesson.Start != 250 {
+		t.Errorf("Expected the availability of the moved lesson at its new start, got %d", notis.handled[3].Noti.Lesson.Start)
+	}
+
+	if len(notis.lessons) != 3 {
+		t.Errorf("Expected all 3 lessons to be updated, got %+v", notis.lessons)
+	}
+}
+
+func TestCheckOnceMovedLaterThatDay(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Amsterdam")
+	morning := uint(time.Date(2030, 6, 15, 10, 0, 0, 0, loc).Unix())
+	evening := uint(time.Date(2030, 6, 15, 18, 0, 0, 0, loc).Unix())
+
+	// The only watched lesson moves far outside its own time span
+	lessons := fakeLessons{{ID: "moved", StartTimestamp: evening, DurationSeconds: 3600}}
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, Lesson: database.Lesson{ID: "moved", Start: morning, DurationSeconds: 3600}},
+	}}
+
+	c := New(Config{
+		Lessons: lessons,
+		Notis:   notis,
+		Format:  formatLessonID{},
+	})
+	c.now = func() time.Time {
+		return time.Date(2030, 6, 15, 8, 0, 0, 0, loc)
+	}
+
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notis.handled) == 0 || notis.handled[0].Message.Text != "moved [moved]" {
+		t.Fatalf("Expected the lesson to be reported as moved, got %+v", notis.handled)
+	}
+
+	if len(notis.lessons) != 1 || notis.lessons[0].Start != evening || notis.lessons[0].Status == statusVanished {
+		t.Errorf("Expected the lesson to be updated to its new start, got %+v", notis.lessons)
+	}
+}
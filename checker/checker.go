@@ -1,67 +1,478 @@
 package checker
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/laytan/go-fff-notifications-bot/database"
 	"github.com/laytan/go-fff-notifications-bot/fitforfree"
-	"gorm.io/gorm"
+	"github.com/laytan/go-fff-notifications-bot/times"
 )
 
-func AvailabilityCheck(db *gorm.DB, venues []string, bearerToken string, availableChan chan database.Noti) {
-	// Get timeframe to get lessons for
-	start, end, notis := getCheckTimeframe(db)
-	if len(notis) == 0 {
-		return
+// Available is sent for every noti whose lesson has a spot available
+type Available struct {
+	Noti database.Noti
+	// Booked is true when the lesson was booked automatically for the user
+	Booked bool
+	// BookErr is why booking automatically failed, nil when it succeeded or was not tried
+	BookErr error
+	// NotFirst is true when the noti books automatically but others were before it in line, so it was not booked
+	NotFirst bool
+}
+
+// LessonSource gets lessons between start and end at the venues with recent availability
+type LessonSource interface {
+	Availability(ctx context.Context, start uint, end uint, venues []string) ([]fitforfree.Lesson, error)
+}
+
+// Handled is a noti that changed during a check, with the message for its user if there is one
+type Handled struct {
+	Noti database.Noti
+	// Retire removes the noti, otherwise its auto booking and watch state are saved
+	Retire  bool
+	Message *database.OutboxMessage
+	// Alert records the alert of the noti, nil when it was not alerted
+	Alert *database.Alert
+}
+
+// NotiStore loads and retires the notis to check
+type NotiStore interface {
+	// Notis returns all notis with their lesson and user
+	Notis(ctx context.Context) ([]database.Noti, error)
+	// Handle queues the messages, records the alerts, retires or updates the notis and updates the lessons in one transaction so no message is lost
+	Handle(ctx context.Context, handled []Handled, lessons []database.Lesson) error
+	// Alerts returns the alerts since the given time
+	Alerts(ctx context.Context, since time.Time) ([]database.Alert, error)
+}
+
+// Formatter returns the messages for the user of a noti
+type Formatter interface {
+	// Available tells the user their lesson has a spot available
+	Available(available Available) database.OutboxMessage
+	// Changed tells the user their lesson changed upstream
+	Changed(change Change) database.OutboxMessage
+	// Started tells the user their lesson started and the noti was removed
+	Started(noti database.Noti) database.OutboxMessage
+}
+
+// Booker books the lesson of a noti for its user
+type Booker interface {
+	Book(ctx context.Context, noti database.Noti) error
+}
+
+const (
+	// DefaultInterval is how often Run checks when no interval is configured
+	DefaultInterval = time.Second * 30
+	// DefaultCooldown is how long a watch waits after alerting when no cooldown is configured
+	DefaultCooldown = time.Minute * 15
+)
+
+// Config configures a Checker, Lessons, Notis and Format are required
+type Config struct {
+	Lessons LessonSource
+	Notis   NotiStore
+	Format  Formatter
+	// Booker books notis that should be booked automatically, they are only notified when nil
+	Booker Booker
+	// Scheduler decides which notis are due every check, all notis are checked when nil
+	Scheduler *Scheduler
+	// Venues to get lessons for when a stored lesson has no venue
+	Venues []string
+	// Interval is the time between checks in Run, defaults to DefaultInterval
+	Interval time.Duration
+	// Cooldown is the minimum time between alerts of a watch, defaults to DefaultCooldown
+	Cooldown time.Duration
+	// Policy decides the order in which the notis of a lesson are alerted, defaults to AllAtOnce
+	Policy Policy
+}
+
+// Checker notifies users when a spot opens in a lesson they have a noti for
+type Checker struct {
+	config Config
+	// now returns the current time, overridden in tests
+	now func() time.Time
+}
+
+// New returns a checker for the config
+func New(config Config) *Checker {
+	if config.Interval == 0 {
+		config.Interval = DefaultInterval
 	}
 
+	if config.Cooldown == 0 {
+		config.Cooldown = DefaultCooldown
+	}
+
+	if config.Policy == nil {
+		config.Policy = AllAtOnce{}
+	}
+
+	return &Checker{config: config, now: time.Now}
+}
+
+// Run checks every interval until the context is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.CheckOnce(ctx); err != nil {
+				log.Printf("ERROR: Error checking availability, err: %+v", err)
+			}
+		}
+	}
+}
+
+// CheckOnce queues a message and retires every due noti whose lesson has a spot available
+// Notis that should be booked automatically are booked first, if that fails the user is alerted with a button to book it themselves
+// Watches are kept after alerting and alert again once the lesson was full and the cooldown passed
+// Notis whose lesson started are not checked anymore, the Cleanup retires them
+// The notis of a lesson are alerted in the order of the policy, messages of later notis are delayed in the outbox and they are not booked automatically
+// Users are told when their lesson is cancelled, moved, gets a new instructor or room or vanishes, the stored lesson is updated to match
+// Messages follow the settings of the user, see deliver
+// Lessons that can't be fetched are skipped, an error is only returned when the notis can't be loaded or handled
+func (c *Checker) CheckOnce(ctx context.Context) error {
+	notis, err := c.config.Notis.Notis(ctx)
+	if err != nil {
+		return fmt.Errorf("can't load notis: %w", err)
+	}
+
+	now := c.now()
+	handled := make([]Handled, 0)
+	lessons := make([]database.Lesson, 0)
+
+	notis = filterUpcoming(notis, now)
+
+	if c.config.Scheduler != nil {
+		notis = c.config.Scheduler.Due(notis)
+	}
+
+	if len(notis) > 0 {
+		checked, updated := c.check(ctx, notis, now)
+		handled = append(handled, checked...)
+		lessons = append(lessons, updated...)
+	}
+
+	if len(handled) > 0 || len(lessons) > 0 {
+		if err := c.config.Notis.Handle(ctx, handled, lessons); err != nil {
+			return fmt.Errorf("can't handle notis: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// check gets the lessons of the notis and returns the notis and stored lessons that changed
+func (c *Checker) check(ctx context.Context, notis []database.Noti, now time.Time) ([]Handled, []database.Lesson) {
+	handled := make([]Handled, 0)
+
+	// Get the windows to get lessons for
+	windows := getCheckWindows(notis, c.config.Venues)
+
 	// Get lessons from fitforfree to check
-	lessons := fitforfree.GetLessons(start, end, venues, bearerToken)
+	lessons, failed := fetchWindows(ctx, c.config.Lessons, windows)
+
+	// Tell users about changes to their lesson, notis of cancelled and vanished lessons end
+	changes, updated := detectChanges(notis, lessons, failed)
+	ended := make(map[uint]bool)
+	for _, change := range changes {
+		message := c.config.Format.Changed(change)
+		deliver(&message, change.Noti.User.Settings, now, false)
+		handled = append(handled, Handled{Noti: change.Noti, Retire: change.Ends(), Message: &message})
+		ended[change.Noti.ID] = change.Ends()
+	}
+
+	// Continue with the notis that still have a lesson, as it is now
+	current := make(map[string]database.Lesson, len(updated))
+	for _, lesson := range updated {
+		current[lesson.ID] = lesson
+	}
+	remaining := make([]database.Noti, 0, len(notis))
+	for _, noti := range notis {
+		if ended[noti.ID] {
+			continue
+		}
+
+		if lesson, ok := current[noti.Lesson.ID]; ok {
+			noti.Lesson = lesson
+		}
+		remaining = append(remaining, noti)
+	}
+	notis = remaining
+
+	// Watches that alerted are armed again when their lesson is full
+	full := make(map[string]bool)
+	for _, lesson := range lessons {
+		full[lesson.ID] = lesson.SpotsAvailable == 0
+	}
+	for _, noti := range notis {
+		if noti.Watch && noti.AlertedAt != nil && !noti.Rearmed && full[noti.Lesson.ID] {
+			noti.Rearmed = true
+			handled = append(handled, Handled{Noti: noti})
+		}
+	}
+
 	lessons = filterUnavailable(lessons)
 
-	// Get notis that are now available
-	availables := filterNotNeeded(lessons, notis)
-	if len(availables) > 0 {
-		primaryKeys := []uint{}
-		for _, a := range availables {
-			primaryKeys = append(primaryKeys, a.ID)
-			// Get the user
-			if err := db.Model(&a).Association("User").Find(&a.User); err != nil {
-				log.Printf("ERROR: No user for noti, which should not happen: %+v", err)
-				break
-			}
-			availableChan <- a
+	// Group the notis that are now available per lesson, in the order of the lessons
+	spots := make(map[string]uint8, len(lessons))
+	for _, lesson := range lessons {
+		spots[lesson.ID] = lesson.SpotsAvailable
+	}
+	groups := make(map[string][]database.Noti)
+	order := make([]string, 0)
+	for _, noti := range filterNotNeeded(lessons, notis) {
+		if !c.armed(noti, now) {
+			continue
 		}
 
-		// Delete notis because they are handled
-		if err := db.Delete(&database.Noti{}, primaryKeys).Error; err != nil {
-			log.Printf("ERROR: Error deleting handled notis: %+v", err)
+		if _, ok := groups[noti.Lesson.ID]; !ok {
+			order = append(order, noti.Lesson.ID)
 		}
+		groups[noti.Lesson.ID] = append(groups[noti.Lesson.ID], noti)
+	}
+
+	if len(order) == 0 {
+		return handled, updated
+	}
+
+	// Without history the policy still orders, it just can't be as fair
+	history, err := c.config.Notis.Alerts(ctx, now.Add(-fairnessHistory))
+	if err != nil {
+		log.Printf("ERROR: Error loading alerts for the fairness policy, err: %+v", err)
 	}
+
+	for _, lessonID := range order {
+		for position, delivery := range c.config.Policy.Order(groups[lessonID], spots[lessonID], history) {
+			handled = append(handled, c.alert(ctx, delivery, position, spots[lessonID], now))
+		}
+	}
+
+	return handled, updated
+}
+
+// alert books the noti of the delivery if it should and returns it with its message delayed by the delivery and its alert
+// Only notis alerted right away are booked automatically, later ones get the spot when the users before them leave it
+func (c *Checker) alert(ctx context.Context, delivery Delivery, position int, spots uint8, now time.Time) Handled {
+	noti := delivery.Noti
+
+	available := Available{Noti: noti}
+	if noti.AutoBook && c.config.Booker != nil {
+		if delivery.Delay > 0 {
+			available.NotFirst = true
+		} else {
+			available.BookErr = c.config.Booker.Book(ctx, noti)
+			available.Booked = available.BookErr == nil
+		}
+	}
+
+	message := c.config.Format.Available(available)
+	if delivery.Delay > 0 {
+		message.NextAttemptAt = now.Add(delivery.Delay)
+	}
+	deliver(&message, noti.User.Settings, now, true)
+
+	h := Handled{
+		Noti:    noti,
+		Message: &message,
+		Alert: &database.Alert{
+			LessonID:    noti.Lesson.ID,
+			LessonName:  noti.Lesson.Name,
+			LessonStart: noti.Lesson.Start,
+			NotiID:      noti.ID,
+			UserID:      noti.UserID,
+			Policy:      c.config.Policy.Name(),
+			Position:    position,
+			Spots:       spots,
+			Delay:       delivery.Delay,
+			Booked:      available.Booked,
+			AlertedAt:   now,
+		},
+	}
+	if available.BookErr != nil {
+		log.Printf("ERROR: Error booking lesson %s for user %d automatically: %+v", noti.Lesson.ID, noti.User.ID, available.BookErr)
+
+		// The user was alerted with a button to book it themselves, a watch keeps watching without booking automatically
+		h.Noti.AutoBook = false
+	}
+
+	switch {
+	case noti.Watch && !available.Booked:
+		h.Noti.AlertedAt = &now
+		h.Noti.Rearmed = false
+	default:
+		h.Retire = true
+	}
+
+	return h
+}
+
+// armed returns if the noti should alert when its lesson has a spot available, notis that are not watches are retired after alerting
+func (c *Checker) armed(noti database.Noti, now time.Time) bool {
+	if noti.AlertedAt == nil {
+		return true
+	}
+
+	return noti.Rearmed && !now.Before(noti.AlertedAt.Add(c.config.Cooldown))
+}
+
+// deliver sets how the message reaches the user by their settings, it is silent in their quiet hours or with the silent style
+// Alerts are urgent and sent right away, other messages wait for the quiet hours to end
+func deliver(message *database.OutboxMessage, settings database.UserSettings, now time.Time, urgent bool) {
+	message.Language = settings.Language
+	message.Silent = settings.Style() == database.StyleSilent
+
+	if !settings.Quiet(now) {
+		return
+	}
+
+	if urgent {
+		message.Silent = true
+	} else if end := settings.QuietEnd(now); end.After(message.NextAttemptAt) {
+		message.NextAttemptAt = end
+	}
+}
+
+// filterUpcoming filters out the notis whose lesson started
+func filterUpcoming(notis []database.Noti, now time.Time) []database.Noti {
+	upcoming := make([]database.Noti, 0, len(notis))
+	for _, noti := range notis {
+		if int64(noti.Lesson.Start) > now.Unix() {
+			upcoming = append(upcoming, noti)
+		}
+	}
+
+	return upcoming
 }
 
-// Gets the lowest start timestamp and the highest end timestamp of all notis in the db
-func getCheckTimeframe(db *gorm.DB) (uint, uint, []database.Noti) {
-	notis := make([]database.Noti, 0)
-	db.Joins("Lesson").Find(&notis)
+// fetchWorkers is the maximum amount of windows fetched at the same time
+const fetchWorkers = 4
+
+// window is a timeframe to get lessons at the venues for, start and end are inclusive
+type window struct {
+	start  uint
+	end    uint
+	venues []string
+}
 
-	if len(notis) == 0 {
-		return 0, 0, notis
+// hasVenue returns if the window gets lessons of the venue, lessons without a venue could be at any
+func (w window) hasVenue(venue string) bool {
+	if venue == "" {
+		return true
 	}
 
-	start := notis[0].Lesson.Start
-	end := notis[0].Lesson.Start + notis[0].Lesson.DurationSeconds
-	for _, noti := range notis[1:] {
-		if start > noti.Lesson.Start {
-			start = noti.Lesson.Start
+	for _, v := range w.venues {
+		if v == venue {
+			return true
 		}
+	}
+	return false
+}
 
-		if end < noti.Lesson.Start+noti.Lesson.DurationSeconds {
-			end = noti.Lesson.Start + noti.Lesson.DurationSeconds
+// getCheckWindows groups the notis per venue and day of their lesson, lessons without a venue are at the fallback venues
+// It returns a window of the whole day for every venue and day, sorted by start
+// The whole day is fetched so lessons that moved on the same day are still found
+func getCheckWindows(notis []database.Noti, fallback []string) []window {
+	days := make(map[string]window)
+	for _, noti := range notis {
+		day := venueDay(noti.Lesson)
+		if _, ok := days[day]; ok {
+			continue
 		}
+
+		start, end := times.Day(noti.Lesson.Start)
+
+		venues := fallback
+		if noti.Lesson.VenueID != "" {
+			venues = []string{noti.Lesson.VenueID}
+		}
+		days[day] = window{start: start, end: end, venues: venues}
 	}
 
-	return start - 1, end + 1, notis
+	windows := make([]window, 0, len(days))
+	for _, w := range days {
+		windows = append(windows, w)
+	}
+
+	sort.Slice(windows, func(i, j int) bool {
+		if windows[i].start == windows[j].start {
+			return strings.Join(windows[i].venues, ",") < strings.Join(windows[j].venues, ",")
+		}
+		return windows[i].start < windows[j].start
+	})
+
+	return windows
+}
+
+// venueDay returns the venue and day of the lesson, the lessons of a venue day are fetched in one request
+func venueDay(lesson database.Lesson) string {
+	start, _ := times.Day(lesson.Start)
+	return fmt.Sprintf("%s %d", lesson.VenueID, start)
+}
+
+// fetchWindows gets the lessons in all windows concurrently with at most fetchWorkers at a time
+// Windows that fail are logged and returned so the other windows can still be checked
+func fetchWindows(ctx context.Context, source LessonSource, windows []window) ([]fitforfree.Lesson, []window) {
+	jobs := make(chan window)
+	results := make(chan []fitforfree.Lesson)
+	failedLock := sync.Mutex{}
+	failed := make([]window, 0)
+
+	workers := fetchWorkers
+	if len(windows) < workers {
+		workers = len(windows)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w := range jobs {
+				lessons, err := source.Availability(ctx, w.start, w.end, w.venues)
+				if err != nil {
+					log.Printf("ERROR: Error getting lessons from %d to %d at %v to check availability, err: %+v", w.start, w.end, w.venues, err)
+					failedLock.Lock()
+					failed = append(failed, w)
+					failedLock.Unlock()
+					continue
+				}
+				results <- lessons
+			}
+		}()
+	}
+
+	go func() {
+		for _, w := range windows {
+			jobs <- w
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Merge the results, lessons in multiple windows are only added once
+	seen := make(map[string]bool)
+	lessons := make([]fitforfree.Lesson, 0)
+	for result := range results {
+		for _, lesson := range result {
+			if !seen[lesson.ID] {
+				seen[lesson.ID] = true
+				lessons = append(lessons, lesson)
+			}
+		}
+	}
+
+	return lessons, failed
 }
 
 // Filters out all lessons that are unavailable
@@ -79,19 +490,18 @@ func filterUnavailable(lessons []fitforfree.Lesson) []fitforfree.Lesson {
 	return lessons[:amt]
 }
 
-// Filters out all lessons we don't have notis for
+// Filters out all notis we don't have lessons for, every noti of a lesson is kept
 func filterNotNeeded(lessons []fitforfree.Lesson, notis []database.Noti) []database.Noti {
-	var n uint
-
+	available := make(map[string]bool, len(lessons))
 	for _, lesson := range lessons {
-		for _, noti := range notis {
-			if noti.Lesson.ID == lesson.ID {
-				notis[n] = noti
-				n++
+		available[lesson.ID] = true
+	}
 
-				// Noti has found it's lesson so we can break
-				break
-			}
+	var n uint
+	for _, noti := range notis {
+		if available[noti.Lesson.ID] {
+			notis[n] = noti
+			n++
 		}
 	}
 
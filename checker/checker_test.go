@@ -1,22 +1,33 @@
 package checker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/database/databasetest"
 	"github.com/laytan/go-fff-notifications-bot/fitforfree"
-	"gorm.io/driver/sqlite"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree/fitforfreetest"
+	"github.com/laytan/go-fff-notifications-bot/times"
 	"gorm.io/gorm"
 )
 
-type getCheckTimeFramePayloads struct {
-	notis    []database.Noti
-	outStart uint
-	outEnd   uint
+type getCheckWindowsPayloads struct {
+	notis      []database.Noti
+	outWindows []window
 }
 
-func TestGetCheckTimeFrame(t *testing.T) {
-	payloads := []getCheckTimeFramePayloads{
+func TestGetCheckWindows(t *testing.T) {
+	day := uint(60 * 60 * 24)
+	// The first day starts at the epoch and ends at midnight in Amsterdam
+	firstStart, firstEnd := times.Day(0)
+	laterStart, laterEnd := times.Day(day * 42)
+	payloads := []getCheckWindowsPayloads{
 		{
 			notis: []database.Noti{
 				{
@@ -26,13 +37,11 @@ func TestGetCheckTimeFrame(t *testing.T) {
 					},
 				},
 			},
-			outStart: 0,
-			outEnd:   11,
+			outWindows: []window{{firstStart, firstEnd, []string{"venue"}}},
 		},
 		{
-			notis:    []database.Noti{},
-			outStart: 0,
-			outEnd:   0,
+			notis:      []database.Noti{},
+			outWindows: []window{},
 		},
 		{
 			notis: []database.Noti{
@@ -51,8 +60,7 @@ func TestGetCheckTimeFrame(t *testing.T) {
 					},
 				},
 			},
-			outStart: 4,
-			outEnd:   16,
+			outWindows: []window{{firstStart, firstEnd, []string{"venue"}}},
 		},
 		{
 			notis: []database.Noti{
@@ -79,46 +87,138 @@ func TestGetCheckTimeFrame(t *testing.T) {
 					},
 				},
 			},
-			outStart: 4,
-			outEnd:   26,
+			outWindows: []window{{firstStart, firstEnd, []string{"venue"}}},
+		},
+		// Lessons on other days are fetched in their own windows
+		{
+			notis: []database.Noti{
+				{
+					Lesson: database.Lesson{
+						ID:              "0",
+						Start:           day*42 + 10,
+						DurationSeconds: 5,
+					},
+				},
+				{
+					Lesson: database.Lesson{
+						ID:              "1",
+						Start:           10,
+						DurationSeconds: 5,
+					},
+				},
+				{
+					Lesson: database.Lesson{
+						ID:              "2",
+						Start:           day*42 + 100,
+						DurationSeconds: 5,
+					},
+				},
+			},
+			outWindows: []window{{firstStart, firstEnd, []string{"venue"}}, {laterStart, laterEnd, []string{"venue"}}},
+		},
+		// Lessons at other venues on the same day are fetched in their own windows
+		{
+			notis: []database.Noti{
+				{
+					Lesson: database.Lesson{
+						ID:              "0",
+						Start:           10,
+						DurationSeconds: 5,
+						VenueID:         "a",
+					},
+				},
+				{
+					Lesson: database.Lesson{
+						ID:              "1",
+						Start:           10,
+						DurationSeconds: 5,
+						VenueID:         "b",
+					},
+				},
+				{
+					Lesson: database.Lesson{
+						ID:              "2",
+						Start:           20,
+						DurationSeconds: 5,
+						VenueID:         "a",
+					},
+				},
+			},
+			outWindows: []window{{firstStart, firstEnd, []string{"a"}}, {firstStart, firstEnd, []string{"b"}}},
 		},
 	}
 
-	// Connect to db
-	db, err := gorm.Open(sqlite.Open("../database/test.sqlite"), &gorm.Config{})
-	if err != nil {
-		t.Error(err)
+	for _, payload := range payloads {
+		windows := getCheckWindows(payload.notis, []string{"venue"})
+		if len(windows) != len(payload.outWindows) {
+			t.Errorf("Expected windows %+v, got %+v", payload.outWindows, windows)
+			continue
+		}
+
+		for i, w := range windows {
+			if !reflect.DeepEqual(w, payload.outWindows[i]) {
+				t.Errorf("Expected window %+v, got %+v", payload.outWindows[i], w)
+			}
+		}
 	}
+}
 
-	if err := db.AutoMigrate(&database.Lesson{}, &database.Noti{}); err != nil {
-		t.Error(err)
+type mockGetter struct {
+	lock      sync.Mutex
+	fetches   int
+	inFlight  int
+	maxFlight int
+}
+
+func (m *mockGetter) GetLessons(ctx context.Context, start uint, end uint, venues []string) ([]fitforfree.Lesson, error) {
+	m.lock.Lock()
+	m.fetches++
+	m.inFlight++
+	if m.inFlight > m.maxFlight {
+		m.maxFlight = m.inFlight
 	}
+	m.lock.Unlock()
 
-	db.Exec("DELETE FROM lessons")
-	db.Exec("DELETE FROM notis")
+	time.Sleep(time.Millisecond * 10)
 
-	for _, payload := range payloads {
-		if len(payload.notis) > 0 {
-			if err := db.Create(&payload.notis).Error; err != nil {
-				t.Error(err)
-			}
-		}
+	m.lock.Lock()
+	m.inFlight--
+	m.lock.Unlock()
 
-		start, end, notis := getCheckTimeframe(db)
-		if start != payload.outStart {
-			t.Error("Start not the same")
-		}
+	if start == 1000 {
+		return nil, errors.New("failed window")
+	}
 
-		if end != payload.outEnd {
-			t.Error("End not the same")
-		}
+	// Lesson 0 is in every window so it should be merged
+	return []fitforfree.Lesson{{ID: "0", StartTimestamp: start}, {ID: fmt.Sprint(start), StartTimestamp: start}}, nil
+}
 
-		if len(notis) != len(payload.notis) {
-			t.Error("Notis not returned correctly")
-		}
+func TestFetchWindows(t *testing.T) {
+	getter := &mockGetter{}
+	cache := fitforfree.NewLessonCache(getter, time.Hour, time.Minute)
+
+	windows := make([]window, 0)
+	for i := uint(0); i < 10; i++ {
+		windows = append(windows, window{start: i * 1000, end: i*1000 + 10, venues: []string{"venue"}})
+	}
+
+	lessons, failed := fetchWindows(context.Background(), cache, windows)
+
+	if getter.fetches != 10 {
+		t.Errorf("Expected 10 fetches, got %d", getter.fetches)
+	}
+
+	if getter.maxFlight > fetchWorkers {
+		t.Errorf("Expected at most %d concurrent fetches, got %d", fetchWorkers, getter.maxFlight)
+	}
+
+	// Lesson 0 and the lessons of the 8 other windows that succeed
+	if len(lessons) != 9 {
+		t.Errorf("Expected 9 lessons, got %d", len(lessons))
+	}
 
-		db.Exec("DELETE FROM lessons")
-		db.Exec("DELETE FROM notis")
+	if len(failed) != 1 || failed[0].start != 1000 {
+		t.Errorf("Expected window 1000 to fail, got %+v", failed)
 	}
 }
 
@@ -231,3 +331,363 @@ func TestFilterUnavailable(t *testing.T) {
 		}
 	}
 }
+
+type fakeLessons []fitforfree.Lesson
+
+func (f fakeLessons) Availability(ctx context.Context, start uint, end uint, venues []string) ([]fitforfree.Lesson, error) {
+	lessons := make([]fitforfree.Lesson, 0)
+	for _, lesson := range f {
+		if lesson.StartTimestamp >= start && lesson.StartTimestamp <= end {
+			lessons = append(lessons, lesson)
+		}
+	}
+	return lessons, nil
+}
+
+type fakeNotis struct {
+	notis   []database.Noti
+	handled []Handled
+	lessons []database.Lesson
+	alerts  []database.Alert
+	err     error
+}
+
+func (f *fakeNotis) Notis(ctx context.Context) ([]database.Noti, error) {
+	return append([]database.Noti{}, f.notis...), f.err
+}
+
+func (f *fakeNotis) Handle(ctx context.Context, handled []Handled, lessons []database.Lesson) error {
+	f.handled = append(f.handled, handled...)
+	f.lessons = append(f.lessons, lessons...)
+
+	// Apply the changes so the next check sees them
+	for _, h := range handled {
+		if h.Alert != nil {
+			f.alerts = append(f.alerts, *h.Alert)
+		}
+
+		for i, noti := range f.notis {
+			if noti.ID != h.Noti.ID {
+				continue
+			}
+
+			if h.Retire {
+				f.notis = append(f.notis[:i], f.notis[i+1:]...)
+			} else {
+				f.notis[i] = h.Noti
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Alerts returns the recorded alerts since the given time
+func (f *fakeNotis) Alerts(ctx context.Context, since time.Time) ([]database.Alert, error) {
+	alerts := make([]database.Alert, 0)
+	for _, alert := range f.alerts {
+		if !alert.AlertedAt.Before(since) {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+// formatLessonID formats messages with the lesson id as text
+type formatLessonID struct{}
+
+// Available has a book button when the lesson was not booked
+func (formatLessonID) Available(available Available) database.OutboxMessage {
+	message := database.OutboxMessage{ChatID: int64(available.Noti.User.ChatID), Text: available.Noti.Lesson.ID}
+	if !available.Booked {
+		message.BookLessonID = available.Noti.Lesson.ID
+	}
+	return message
+}
+
+// Changed has the kinds of change after the lesson id
+func (formatLessonID) Changed(change Change) database.OutboxMessage {
+	return database.OutboxMessage{ChatID: int64(change.Noti.User.ChatID), Text: fmt.Sprintf("%s %v", change.Noti.Lesson.ID, change.Kinds)}
+}
+
+// Started has started after the lesson id
+func (formatLessonID) Started(noti database.Noti) database.OutboxMessage {
+	return database.OutboxMessage{ChatID: int64(noti.User.ChatID), Text: fmt.Sprintf("%s started", noti.Lesson.ID)}
+}
+
+type fakeBooker map[string]error
+
+func (f fakeBooker) Book(ctx context.Context, noti database.Noti) error {
+	return f[noti.Lesson.ID]
+}
+
+func TestCheckOnce(t *testing.T) {
+	lessons := fakeLessons{
+		{ID: "open", StartTimestamp: 100, SpotsAvailable: 1},
+		{ID: "full", StartTimestamp: 200, SpotsAvailable: 0},
+		{ID: "bookable", StartTimestamp: 300, SpotsAvailable: 2},
+		{ID: "unbookable", StartTimestamp: 400, SpotsAvailable: 2},
+		{ID: "unbookable-watch", StartTimestamp: 500, SpotsAvailable: 2},
+	}
+
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, Lesson: database.Lesson{ID: "open", Start: 100}},
+		{Model: gorm.Model{ID: 2}, Lesson: database.Lesson{ID: "open", Start: 100}},
+		{Model: gorm.Model{ID: 3}, Lesson: database.Lesson{ID: "full", Start: 200}},
+		{Model: gorm.Model{ID: 4}, Lesson: database.Lesson{ID: "bookable", Start: 300}, AutoBook: true},
+		{Model: gorm.Model{ID: 5}, Lesson: database.Lesson{ID: "unbookable", Start: 400}, AutoBook: true},
+		{Model: gorm.Model{ID: 6}, Lesson: database.Lesson{ID: "unbookable-watch", Start: 500}, AutoBook: true, Watch: true},
+	}}
+	c := New(Config{
+		Lessons: lessons,
+		Notis:   notis,
+		Format:  formatLessonID{},
+		Booker:  fakeBooker{"unbookable": fitforfree.ErrUpstream, "unbookable-watch": fitforfree.ErrUpstream},
+	})
+	c.now = func() time.Time {
+		return time.Unix(0, 0)
+	}
+
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both notis of the open lesson are notified
+	if len(notis.handled) != 5 {
+		t.Fatalf("Expected 5 handled notis, got %+v", notis.handled)
+	}
+
+	for _, h := range notis.handled {
+		if h.Message == nil || h.Message.Text != h.Noti.Lesson.ID {
+			t.Errorf("Expected the formatted message for lesson %s, got %+v", h.Noti.Lesson.ID, h.Message)
+			continue
+		}
+
+		switch h.Noti.Lesson.ID {
+		case "open":
+			if !h.Retire {
+				t.Error("Notis of lesson open should be retired")
+			}
+		case "bookable":
+			if !h.Retire || h.Message.BookLessonID != "" {
+				t.Error("Lesson bookable should be booked and retired")
+			}
+		case "unbookable":
+			if !h.Retire || h.Message.BookLessonID != "unbookable" {
+				t.Error("Noti of lesson unbookable should be alerted with a button to book it and retired")
+			}
+		case "unbookable-watch":
+			if h.Retire || h.Noti.AutoBook || h.Noti.AlertedAt == nil || h.Message.BookLessonID != "unbookable-watch" {
+				t.Error("Watch of lesson unbookable-watch should be alerted with a button to book it and keep watching without auto booking")
+			}
+		case "full":
+			t.Error("Lesson full should not be notified")
+		}
+	}
+
+	// The notis whose booking failed were alerted already and are not alerted again
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(notis.handled) != 5 {
+		t.Errorf("Expected no more messages after the failed bookings were alerted, got %+v", notis.handled[5:])
+	}
+
+	// Only the watch whose booking failed is left to alert again once the lesson was full
+	if len(notis.notis) != 2 || notis.notis[1].Lesson.ID != "unbookable-watch" || notis.notis[1].AutoBook {
+		t.Errorf("Expected the full lesson and the watch without auto booking to remain, got %+v", notis.notis)
+	}
+
+	notis.err = errors.New("database down")
+	if err := c.CheckOnce(context.Background()); err == nil {
+		t.Error("Expected an error when notis can't be loaded")
+	}
+}
+
+func TestCheckOnceQuietHours(t *testing.T) {
+	lessons := fakeLessons{
+		{ID: "open", StartTimestamp: 100000, SpotsAvailable: 1},
+		{ID: "moved", StartTimestamp: 200000, SpotsAvailable: 0},
+	}
+
+	quiet := database.User{ChatID: 1, Settings: database.UserSettings{Timezone: "UTC", QuietFrom: 22, QuietUntil: 7, Language: "en"}}
+	silent := database.User{ChatID: 2, Settings: database.UserSettings{NotificationStyle: database.StyleSilent}}
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, User: quiet, Lesson: database.Lesson{ID: "open", Start: 100000}},
+		{Model: gorm.Model{ID: 2}, User: quiet, Lesson: database.Lesson{ID: "moved", Start: 100000}},
+		{Model: gorm.Model{ID: 3}, User: silent, Lesson: database.Lesson{ID: "open", Start: 100000}},
+	}}
+	c := New(Config{
+		Lessons: lessons,
+		Notis:   notis,
+		Format:  formatLessonID{},
+	})
+	// 23:00 UTC, in the quiet hours of the first user
+	now := time.Date(1970, 1, 1, 23, 0, 0, 0, time.UTC)
+	c.now = func() time.Time {
+		return now
+	}
+
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notis.handled) != 3 {
+		t.Fatalf("Expected 3 handled notis, got %+v", notis.handled)
+	}
+
+	for _, h := range notis.handled {
+		switch h.Noti.ID {
+		case 1:
+			// Alerts are sent right away, without sound
+			if !h.Message.Silent || !h.Message.NextAttemptAt.IsZero() || h.Message.Language != "en" {
+				t.Errorf("Expected a silent alert right away in english, got %+v", h.Message)
+			}
+		case 2:
+			// Changes wait for the quiet hours to end
+			if h.Message.Silent || !h.Message.NextAttemptAt.Equal(now.Add(time.Hour*8)) {
+				t.Errorf("Expected the change to wait for 07:00, got %+v", h.Message)
+			}
+		case 3:
+			if !h.Message.Silent || !h.Message.NextAttemptAt.IsZero() {
+				t.Errorf("Expected a silent alert right away for the silent style, got %+v", h.Message)
+			}
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	now := time.Unix(0, 0)
+	lessons := fakeLessons{
+		{ID: "watched", StartTimestamp: 3600, SpotsAvailable: 1},
+		{ID: "started", StartTimestamp: 60, SpotsAvailable: 0},
+	}
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, Lesson: database.Lesson{ID: "watched", Start: 3600}, Watch: true},
+		{Model: gorm.Model{ID: 2}, Lesson: database.Lesson{ID: "started", Start: 60}, Watch: true},
+	}}
+
+	c := New(Config{
+		Lessons:  &lessons,
+		Notis:    notis,
+		Format:   formatLessonID{},
+		Cooldown: time.Minute * 10,
+	})
+	c.now = func() time.Time {
+		return now
+	}
+
+	alerts := func() int {
+		n := 0
+		for _, h := range notis.handled {
+			if h.Message != nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	check := func(spots uint8, after time.Duration) {
+		now = now.Add(after)
+		lessons[0].SpotsAvailable = spots
+		if err := c.CheckOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check(1, 0)
+	if alerts() != 1 || len(notis.notis) != 2 {
+		t.Fatalf("Expected the watch to alert and stay, got %d alerts and %d notis", alerts(), len(notis.notis))
+	}
+
+	// Full and open again, but within the cooldown
+	check(0, time.Minute)
+	check(1, 0)
+	if alerts() != 1 {
+		t.Error("Expected no alert within the cooldown")
+	}
+
+	check(1, time.Minute*10)
+	if alerts() != 2 {
+		t.Errorf("Expected the watch to alert again after the cooldown, got %d alerts", alerts())
+	}
+
+	// Still open, so no new alert
+	check(1, time.Minute*20)
+	if alerts() != 2 {
+		t.Error("Expected no alert while the lesson stayed open")
+	}
+
+	// Watches of started lessons are not checked anymore, the cleanup retires them
+	check(0, time.Minute)
+	check(1, time.Hour)
+	if len(notis.notis) != 2 || alerts() != 2 {
+		t.Errorf("Expected no alerts after the lesson started, got %d alerts", alerts())
+	}
+}
+
+func TestAvailabilityCheck(t *testing.T) {
+	databasetest.Run(t, testAvailabilityCheck)
+}
+
+func testAvailabilityCheck(t *testing.T, db *gorm.DB) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("123", "1234AB", fitforfree.User{})
+	server.AddMember("bot", "0000AA", fitforfree.User{})
+
+	start := uint(time.Now().Add(time.Hour * 24).Unix())
+	for i, spots := range []uint8{1, 1, 0} {
+		server.AddLesson(fitforfree.Lesson{ID: fmt.Sprint(i), VenueName: "venue", StartTimestamp: start + uint(i)*3600, DurationSeconds: 3600, SpotsAvailable: spots})
+	}
+
+	sealer, _ := database.NewSealer([]byte("01234567890123456789012345678901"))
+	user := database.User{ID: 1, ChatID: 1}
+	user.SetSession(sealer, fitforfree.User{SessionID: server.Token("123")})
+	db.Create(&user)
+
+	notis := []database.Noti{
+		{UserID: 1, Lesson: database.Lesson{ID: "0", Start: start, DurationSeconds: 3600}},
+		{UserID: 1, Lesson: database.Lesson{ID: "1", Start: start + 3600, DurationSeconds: 3600}, AutoBook: true},
+		{UserID: 1, Lesson: database.Lesson{ID: "2", Start: start + 7200, DurationSeconds: 3600}},
+	}
+	db.Create(&notis)
+
+	client := server.Client(fitforfree.Config{})
+	cache := fitforfree.NewLessonCache(client.NewSession("bot", "0000AA", nil), time.Hour, 0)
+	c := New(Config{
+		Lessons: cache,
+		Notis:   NewNotiStore(db),
+		Format:  formatLessonID{},
+		Booker:  NewBooker(client, sealer),
+		Venues:  []string{"venue"},
+	})
+
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []database.OutboxMessage{}
+	db.Order("text").Find(&messages)
+	if len(messages) != 2 || messages[0].Text != "0" || messages[1].Text != "1" || messages[0].ChatID != 1 {
+		t.Fatalf("Expected messages for lesson 0 and 1 in the outbox, got %+v", messages)
+	}
+
+	if !server.Booked("123", "1") || server.Booked("123", "0") {
+		t.Error("Only lesson 1 should be booked automatically")
+	}
+
+	remaining := []database.Noti{}
+	db.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].LessonID != "2" {
+		t.Errorf("Only the noti of the full lesson should remain, got %+v", remaining)
+	}
+
+	alerts, err := database.Alerts(db, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Policy != "all" || alerts[0].User.ChatID != 1 {
+		t.Errorf("Expected the 2 alerts to be recorded with their user, got %+v", alerts)
+	}
+}
@@ -0,0 +1,92 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/forecast"
+)
+
+// DefaultRetention is how long deleted notis and lessons are kept when no retention is configured
+const DefaultRetention = time.Hour * 24 * 30
+
+// Cleanup retires the notis whose lesson started and purges notis and lessons that were deleted long ago
+type Cleanup struct {
+	repositories database.Repositories
+	format       Formatter
+	retention    time.Duration
+	// now returns the current time, overridden in tests
+	now func() time.Time
+}
+
+// NewCleanup returns a cleanup of the repositories that tells users with format that their lesson started
+// Deleted notis and lessons are kept for retention before they are purged, DefaultRetention when it is 0
+func NewCleanup(repositories database.Repositories, format Formatter, retention time.Duration) *Cleanup {
+	if retention == 0 {
+		retention = DefaultRetention
+	}
+
+	return &Cleanup{repositories: repositories, format: format, retention: retention, now: time.Now}
+}
+
+// Run cleans up every interval until the context is done
+func (c *Cleanup) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.CleanOnce(ctx); err != nil {
+				log.Printf("ERROR: Error cleaning up, err: %+v", err)
+			}
+		}
+	}
+}
+
+// CleanOnce retires the notis whose lesson started, queueing a message for their users in the same transaction
+// The messages wait for the quiet hours of the users to end
+// Lessons that started without notis are deleted, and rows deleted longer than the retention ago are purged
+// Observations of lessons too old for forecasts are purged as well
+func (c *Cleanup) CleanOnce(ctx context.Context) error {
+	now := c.now()
+
+	notis, err := c.repositories.Cleanup.StartedNotis(ctx, now)
+	if err != nil {
+		return fmt.Errorf("can't load notis of started lessons: %w", err)
+	}
+
+	for _, noti := range notis {
+		message := c.format.Started(noti)
+		deliver(&message, noti.User.Settings, now, false)
+		if err := c.repositories.Cleanup.Retire(ctx, noti, &message); err != nil {
+			return fmt.Errorf("can't retire noti %d of a started lesson: %w", noti.ID, err)
+		}
+	}
+
+	lessons, err := c.repositories.Cleanup.DeleteStartedLessons(ctx, now)
+	if err != nil {
+		return fmt.Errorf("can't delete started lessons: %w", err)
+	}
+
+	purgedNotis, purgedLessons, err := c.repositories.Cleanup.Purge(ctx, now.Add(-c.retention))
+	if err != nil {
+		return fmt.Errorf("can't purge deleted rows: %w", err)
+	}
+
+	observations, err := c.repositories.Observations.Purge(ctx, now.Add(-forecast.History))
+	if err != nil {
+		return fmt.Errorf("can't purge old observations: %w", err)
+	}
+
+	if len(notis) > 0 || lessons > 0 || purgedNotis > 0 || purgedLessons > 0 || observations > 0 {
+		log.Printf("Cleaned up %d notis and %d lessons that started, purged %d notis, %d lessons and %d observations", len(notis), lessons, purgedNotis, purgedLessons, observations)
+	}
+
+	return nil
+}
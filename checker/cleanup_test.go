@@ -0,0 +1,83 @@
+package checker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/database/databasetest"
+	"github.com/laytan/go-fff-notifications-bot/forecast"
+	"gorm.io/gorm"
+)
+
+func TestCleanOnce(t *testing.T) {
+	databasetest.Run(t, testCleanOnce)
+}
+
+func testCleanOnce(t *testing.T, db *gorm.DB) {
+	now := time.Now()
+	db.Create(&database.User{ID: 1, ChatID: 1})
+	db.Create(&[]database.Noti{
+		{UserID: 1, Lesson: database.Lesson{ID: "started", Start: uint(now.Add(-time.Minute).Unix())}},
+		{UserID: 1, Lesson: database.Lesson{ID: "upcoming", Start: uint(now.Add(time.Hour).Unix())}},
+	})
+
+	// A noti and lesson deleted long ago, and a noti deleted recently
+	old := database.Noti{UserID: 1, Lesson: database.Lesson{ID: "old", Start: uint(now.Add(-time.Hour * 24 * 60).Unix())}}
+	db.Create(&old)
+	db.Delete(&old)
+	db.Delete(&old.Lesson)
+	db.Exec("UPDATE notis SET deleted_at = ? WHERE id = ?", now.Add(-time.Hour*24*40), old.ID)
+	db.Exec("UPDATE lessons SET deleted_at = ? WHERE id = ?", now.Add(-time.Hour*24*40), "old")
+
+	recent := database.Noti{UserID: 1, LessonID: "upcoming"}
+	db.Create(&recent)
+	db.Delete(&recent)
+
+	// An observation of a lesson too old for forecasts, and one of a lesson forecasts still look at
+	db.Create(&[]database.Observation{
+		{LessonID: "ancient", LessonStart: uint(now.Add(-forecast.History - time.Hour).Unix())},
+		{LessonID: "recent", LessonStart: uint(now.Add(-forecast.History + time.Hour).Unix())},
+	})
+
+	cleanup := NewCleanup(database.NewRepositories(db), formatLessonID{}, time.Hour*24*30)
+	if err := cleanup.CleanOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []database.OutboxMessage{}
+	db.Find(&messages)
+	if len(messages) != 1 || messages[0].Text != "started started" || messages[0].ChatID != 1 {
+		t.Errorf("Expected a message for the started lesson, got %+v", messages)
+	}
+
+	notis := []database.Noti{}
+	db.Find(&notis)
+	if len(notis) != 1 || notis[0].LessonID != "upcoming" {
+		t.Errorf("Expected only the noti of the upcoming lesson to remain, got %+v", notis)
+	}
+
+	lessons := []database.Lesson{}
+	db.Find(&lessons)
+	if len(lessons) != 1 || lessons[0].ID != "upcoming" {
+		t.Errorf("Expected only the upcoming lesson to remain, got %+v", lessons)
+	}
+
+	var all int64
+	db.Unscoped().Model(&database.Noti{}).Count(&all)
+	if all != 3 {
+		t.Errorf("Expected the old noti to be purged and the recently deleted notis to be kept, got %d notis", all)
+	}
+
+	db.Unscoped().Model(&database.Lesson{}).Count(&all)
+	if all != 2 {
+		t.Errorf("Expected the old lesson to be purged, got %d lessons", all)
+	}
+
+	observations := []database.Observation{}
+	db.Find(&observations)
+	if len(observations) != 1 || observations[0].LessonID != "recent" {
+		t.Errorf("Expected only the observation forecasts still use to remain, got %+v", observations)
+	}
+}
@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+)
+
+// fairnessHistory is how far back the alerts go that policies base their order on
+const fairnessHistory = time.Hour * 24 * 7
+
+// Delivery is a noti to alert and how long after the first noti of its lesson to alert it
+type Delivery struct {
+	Noti  database.Noti
+	Delay time.Duration
+}
+
+// Policy decides in which order and when the notis of a lesson with spots available are alerted
+type Policy interface {
+	// Name is recorded with every alert
+	Name() string
+	// Order returns a delivery for every noti, history are the alerts of the last week
+	Order(notis []database.Noti, spots uint8, history []database.Alert) []Delivery
+}
+
+// AllAtOnce alerts every noti at the same time, the default policy
+type AllAtOnce struct{}
+
+// Name returns "all"
+func (AllAtOnce) Name() string {
+	return "all"
+}
+
+// Order returns the notis in the order they were created without delay
+func (AllAtOnce) Order(notis []database.Noti, spots uint8, history []database.Alert) []Delivery {
+	return stagger(notis, spots, 0)
+}
+
+// Rotating alerts the users that were at the front of the line least recently first
+// A group the size of the spots available is alerted every Stagger
+type Rotating struct {
+	Stagger time.Duration
+}
+
+// Name returns "rotating"
+func (Rotating) Name() string {
+	return "rotating"
+}
+
+// Order puts users that were never at the front first, then the ones that were at the front longest ago
+func (r Rotating) Order(notis []database.Noti, spots uint8, history []database.Alert) []Delivery {
+	front := make(map[uint]time.Time)
+	for _, alert := range history {
+		if alert.Position < int(alert.Spots) && alert.AlertedAt.After(front[alert.UserID]) {
+			front[alert.UserID] = alert.AlertedAt
+		}
+	}
+
+	ordered := append([]database.Noti{}, notis...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return front[ordered[i].UserID].Before(front[ordered[j].UserID])
+	})
+
+	return stagger(ordered, spots, r.Stagger)
+}
+
+// FavourLosers alerts the users that lost the most recent races first
+// A group the size of the spots available is alerted every Stagger
+type FavourLosers struct {
+	Stagger time.Duration
+}
+
+// Name returns "losers"
+func (FavourLosers) Name() string {
+	return "losers"
+}
+
+// Order puts users with the most lost alerts of the last week first
+func (f FavourLosers) Order(notis []database.Noti, spots uint8, history []database.Alert) []Delivery {
+	lost := make(map[uint]int)
+	for _, alert := range history {
+		if alert.Lost() {
+			lost[alert.UserID]++
+		}
+	}
+
+	ordered := append([]database.Noti{}, notis...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return lost[ordered[i].UserID] > lost[ordered[j].UserID]
+	})
+
+	return stagger(ordered, spots, f.Stagger)
+}
+
+// stagger delays every group of spots notis by another interval, in order
+func stagger(notis []database.Noti, spots uint8, interval time.Duration) []Delivery {
+	group := int(spots)
+	if group < 1 {
+		group = 1
+	}
+
+	deliveries := make([]Delivery, 0, len(notis))
+	for i, noti := range notis {
+		deliveries = append(deliveries, Delivery{Noti: noti, Delay: time.Duration(i/group) * interval})
+	}
+	return deliveries
+}
@@ -0,0 +1,203 @@
+package checker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"gorm.io/gorm"
+)
+
+type policyPayload struct {
+	policy  Policy
+	spots   uint8
+	history []database.Alert
+	// users in the expected order
+	users  []uint
+	delays []time.Duration
+}
+
+func TestPolicies(t *testing.T) {
+	now := time.Unix(10000, 0)
+	notis := []database.Noti{
+		{Model: gorm.Model{ID: 1}, UserID: 1},
+		{Model: gorm.Model{ID: 2}, UserID: 2},
+		{Model: gorm.Model{ID: 3}, UserID: 3},
+	}
+
+	payloads := []policyPayload{
+		{
+			policy: AllAtOnce{},
+			spots:  1,
+			users:  []uint{1, 2, 3},
+			delays: []time.Duration{0, 0, 0},
+		},
+		// User 1 was at the front most recently so goes last, user 3 never was so goes first
+		{
+			policy: Rotating{Stagger: time.Minute},
+			spots:  1,
+			history: []database.Alert{
+				{UserID: 1, Position: 0, Spots: 1, AlertedAt: now.Add(-time.Hour)},
+				{UserID: 2, Position: 0, Spots: 1, AlertedAt: now.Add(-time.Hour * 2)},
+				{UserID: 3, Position: 1, Spots: 1, AlertedAt: now.Add(-time.Hour)},
+			},
+			users:  []uint{3, 2, 1},
+			delays: []time.Duration{0, time.Minute, time.Minute * 2},
+		},
+		// Groups are as big as the spots available
+		{
+			policy: Rotating{Stagger: time.Minute},
+			spots:  2,
+			users:  []uint{1, 2, 3},
+			delays: []time.Duration{0, 0, time.Minute},
+		},
+		// User 3 lost twice and user 2 once, a booked alert is not lost
+		{
+			policy: FavourLosers{Stagger: time.Minute},
+			spots:  1,
+			history: []database.Alert{
+				{UserID: 3, Position: 1, Spots: 1},
+				{UserID: 3, Position: 2, Spots: 1},
+				{UserID: 2, Position: 1, Spots: 1},
+				{UserID: 1, Position: 1, Spots: 1, Booked: true},
+			},
+			users:  []uint{3, 2, 1},
+			delays: []time.Duration{0, time.Minute, time.Minute * 2},
+		},
+	}
+
+	for i, payload := range payloads {
+		deliveries := payload.policy.Order(notis, payload.spots, payload.history)
+
+		users := make([]uint, 0)
+		delays := make([]time.Duration, 0)
+		for _, delivery := range deliveries {
+			users = append(users, delivery.Noti.UserID)
+			delays = append(delays, delivery.Delay)
+		}
+
+		if !reflect.DeepEqual(users, payload.users) || !reflect.DeepEqual(delays, payload.delays) {
+			t.Errorf("Payload %d: expected users %v with delays %v, got %v with %v", i, payload.users, payload.delays, users, delays)
+		}
+	}
+}
+
+func TestCheckOnceFairness(t *testing.T) {
+	lessons := fakeLessons{{ID: "full", StartTimestamp: 10000, SpotsAvailable: 1}}
+
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, UserID: 1, Lesson: database.Lesson{ID: "full", Start: 10000}, Watch: true},
+		{Model: gorm.Model{ID: 2}, UserID: 2, Lesson: database.Lesson{ID: "full", Start: 10000}, Watch: true},
+	}}
+
+	c := New(Config{
+		Lessons:  lessons,
+		Notis:    notis,
+		Format:   formatLessonID{},
+		Policy:   Rotating{Stagger: time.Minute},
+		Cooldown: time.Second,
+	})
+	now := time.Unix(0, 0)
+	c.now = func() time.Time {
+		return now
+	}
+
+	// The lesson opens, fills up and opens again, the front of the line rotates
+	for round, first := range []uint{1, 2, 1} {
+		lessons[0].SpotsAvailable = 1
+		if err := c.CheckOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		alerts := notis.alerts[len(notis.alerts)-2:]
+		if alerts[0].UserID != first || alerts[0].Position != 0 || alerts[1].Position != 1 || alerts[0].Policy != "rotating" {
+			t.Errorf("Round %d: expected user %d to be alerted first, got %+v", round, first, alerts)
+		}
+
+		messages := notis.handled[len(notis.handled)-2:]
+		if !messages[0].Message.NextAttemptAt.IsZero() || messages[1].Message.NextAttemptAt != now.Add(time.Minute) {
+			t.Errorf("Round %d: expected the second message to be delayed a minute, got %+v", round, messages)
+		}
+
+		now = now.Add(time.Minute)
+		lessons[0].SpotsAvailable = 0
+		if err := c.CheckOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+}
+
+// recordingBooker books every noti and records which
+type recordingBooker struct {
+	booked []uint
+}
+
+func (r *recordingBooker) Book(ctx context.Context, noti database.Noti) error {
+	r.booked = append(r.booked, noti.ID)
+	return nil
+}
+
+func TestCheckOnceFairnessAutoBook(t *testing.T) {
+	lessons := fakeLessons{{ID: "full", StartTimestamp: 10000, SpotsAvailable: 1}}
+
+	// User 1 is first in line and books themselves, user 2 is later and books automatically
+	notis := &fakeNotis{notis: []database.Noti{
+		{Model: gorm.Model{ID: 1}, UserID: 1, Lesson: database.Lesson{ID: "full", Start: 10000}, Watch: true},
+		{Model: gorm.Model{ID: 2}, UserID: 2, Lesson: database.Lesson{ID: "full", Start: 10000}, Watch: true, AutoBook: true},
+	}}
+
+	booker := &recordingBooker{}
+	c := New(Config{
+		Lessons:  lessons,
+		Notis:    notis,
+		Format:   formatLessonID{},
+		Booker:   booker,
+		Policy:   Rotating{Stagger: time.Minute},
+		Cooldown: time.Second,
+	})
+	now := time.Unix(0, 0)
+	c.now = func() time.Time {
+		return now
+	}
+
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The spot is left to user 1 for the stagger instead of being taken by user 2
+	if len(booker.booked) != 0 {
+		t.Errorf("Expected no automatic booking before the first in line was alerted, booked %v", booker.booked)
+	}
+
+	if len(notis.handled) != 2 {
+		t.Fatalf("Expected both notis to be alerted, got %+v", notis.handled)
+	}
+
+	later := notis.handled[1]
+	if later.Noti.ID != 2 || later.Retire || !later.Noti.AutoBook || later.Alert.Booked {
+		t.Errorf("Expected the later watch to be kept with automatic booking, got %+v", later)
+	}
+	if later.Message.NextAttemptAt != now.Add(time.Minute) || later.Message.BookLessonID != "full" {
+		t.Errorf("Expected the later watch to be alerted a minute later with a button to book, got %+v", later.Message)
+	}
+
+	// When user 2 is first in line the next time, the lesson is booked right away
+	lessons[0].SpotsAvailable = 0
+	now = now.Add(time.Minute)
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	lessons[0].SpotsAvailable = 1
+	now = now.Add(time.Minute)
+	if err := c.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(booker.booked) != 1 || booker.booked[0] != 2 {
+		t.Errorf("Expected user 2 to be booked automatically when first in line, booked %v", booker.booked)
+	}
+}
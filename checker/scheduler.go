@@ -0,0 +1,169 @@
+package checker
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+)
+
+// RushWindow is a period before the start of a lesson in which a lot of spots open up
+type RushWindow struct {
+	// From and To are durations before the start of the lesson, From is the longest
+	From time.Duration
+	To   time.Duration
+}
+
+// DefaultRushWindows are right before the free cancellation deadline 24 hours before the lesson and the last 2 hours
+var DefaultRushWindows = []RushWindow{
+	{From: time.Hour * 26, To: time.Hour * 23},
+	{From: time.Hour * 2, To: 0},
+}
+
+// pollStep is how often a lesson is polled when it starts within Before
+type pollStep struct {
+	Before   time.Duration
+	Interval time.Duration
+}
+
+// pollSteps are ordered from the closest to the furthest start
+var pollSteps = []pollStep{
+	{Before: time.Hour, Interval: time.Minute},
+	{Before: time.Hour * 6, Interval: time.Minute * 2},
+	{Before: time.Hour * 24, Interval: time.Minute * 5},
+	{Before: time.Hour * 24 * 3, Interval: time.Minute * 15},
+	{Before: time.Hour * 24 * 7, Interval: time.Minute * 30},
+}
+
+// farInterval is how often lessons further out than all pollSteps are polled
+const farInterval = time.Hour * 2
+
+// minInterval is the shortest interval, also in rush windows
+const minInterval = time.Minute
+
+// Scheduler decides which watched lessons should be checked, polling lessons more often as their start gets closer
+type Scheduler struct {
+	// Budget is the maximum amount of requests per hour, intervals are stretched to stay within it, 0 is unlimited
+	Budget      int
+	RushWindows []RushWindow
+
+	// now is replaced in tests
+	now func() time.Time
+
+	lock sync.Mutex
+	// lastChecked is when every venue day was last checked
+	lastChecked map[string]time.Time
+	lastFactor  float64
+}
+
+// NewScheduler returns a scheduler that stays within budget requests per hour
+func NewScheduler(budget int, rushWindows []RushWindow) *Scheduler {
+	return &Scheduler{
+		Budget:      budget,
+		RushWindows: rushWindows,
+		now:         time.Now,
+		lastChecked: make(map[string]time.Time),
+		lastFactor:  1,
+	}
+}
+
+// Due returns the notis whose lesson should be checked now and marks their venue days as checked
+// One request checks a whole day at a venue, so a day is polled at the shortest interval of its lessons and all its notis are due together
+// Notis of lessons that already started are never due
+func (s *Scheduler) Due(notis []database.Noti) []database.Noti {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+
+	dayIntervals := make(map[string]time.Duration)
+	for _, noti := range notis {
+		interval := s.interval(noti.Lesson, now)
+		if interval == 0 {
+			continue
+		}
+
+		day := venueDay(noti.Lesson)
+		if current, ok := dayIntervals[day]; !ok || interval < current {
+			dayIntervals[day] = interval
+		}
+	}
+
+	factor := s.budgetFactor(dayIntervals)
+
+	dueDays := make(map[string]bool)
+	for day, interval := range dayIntervals {
+		last, wasChecked := s.lastChecked[day]
+		if !wasChecked || now.Sub(last) >= time.Duration(float64(interval)*factor) {
+			dueDays[day] = true
+			s.lastChecked[day] = now
+		}
+	}
+
+	// Forget days that are not watched anymore
+	for day := range s.lastChecked {
+		if _, ok := dayIntervals[day]; !ok {
+			delete(s.lastChecked, day)
+		}
+	}
+
+	due := make([]database.Noti, 0)
+	for _, noti := range notis {
+		if dueDays[venueDay(noti.Lesson)] && s.interval(noti.Lesson, now) != 0 {
+			due = append(due, noti)
+		}
+	}
+
+	return due
+}
+
+// interval returns how often the lesson should be polled, 0 when it already started
+func (s *Scheduler) interval(lesson database.Lesson, now time.Time) time.Duration {
+	untilStart := time.Unix(int64(lesson.Start), 0).Sub(now)
+	if untilStart <= 0 {
+		return 0
+	}
+
+	interval := farInterval
+	for _, step := range pollSteps {
+		if untilStart <= step.Before {
+			interval = step.Interval
+			break
+		}
+	}
+
+	for _, rush := range s.RushWindows {
+		if untilStart <= rush.From && untilStart >= rush.To {
+			interval /= 2
+			break
+		}
+	}
+
+	if interval < minInterval {
+		interval = minInterval
+	}
+	return interval
+}
+
+// budgetFactor returns how much the intervals should be stretched to stay within the budget
+func (s *Scheduler) budgetFactor(dayIntervals map[string]time.Duration) float64 {
+	factor := 1.0
+	if s.Budget > 0 {
+		perHour := 0.0
+		for _, interval := range dayIntervals {
+			perHour += float64(time.Hour) / float64(interval)
+		}
+
+		if perHour > float64(s.Budget) {
+			factor = perHour / float64(s.Budget)
+		}
+	}
+
+	if factor != s.lastFactor {
+		log.Printf("Polling %d venue days of lessons, intervals stretched %.2fx to stay within %d requests per hour", len(dayIntervals), factor, s.Budget)
+		s.lastFactor = factor
+	}
+
+	return factor
+}
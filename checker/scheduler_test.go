@@ -0,0 +1,167 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/gorm"
+)
+
+func newSchedulerNoti(id string, start time.Time) database.Noti {
+	return database.Noti{Lesson: database.Lesson{ID: id, Start: uint(start.Unix())}}
+}
+
+func TestSchedulerPollsCloseLessonsMoreOften(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduler := NewScheduler(0, nil)
+	scheduler.now = func() time.Time {
+		return now
+	}
+
+	notis := []database.Noti{
+		newSchedulerNoti("soon", now.Add(time.Minute*30)),
+		newSchedulerNoti("far", now.Add(time.Hour*24*21)),
+		newSchedulerNoti("started", now.Add(-time.Minute)),
+	}
+
+	checks := make(map[string]int)
+	for i := 0; i < 60; i++ {
+		for _, noti := range scheduler.Due(notis) {
+			checks[noti.Lesson.ID]++
+		}
+		now = now.Add(time.Minute)
+	}
+
+	if checks["started"] != 0 {
+		t.Error("Lessons that started should not be checked")
+	}
+
+	// soon is checked every minute until it starts 30 minutes in
+	if checks["soon"] != 30 {
+		t.Errorf("Expected soon to be checked 30 times, got %d", checks["soon"])
+	}
+
+	if checks["far"] != 1 {
+		t.Errorf("Expected far to be checked once, got %d", checks["far"])
+	}
+}
+
+func TestSchedulerRushWindows(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	start := now.Add(time.Hour * 48)
+
+	normal := NewScheduler(0, nil)
+	rush := NewScheduler(0, []RushWindow{{From: time.Hour * 49, To: time.Hour * 47}})
+
+	if normal.interval(newSchedulerNoti("1", start).Lesson, now) != time.Minute*15 {
+		t.Error("Expected 15 minutes for a lesson in 2 days")
+	}
+
+	if rush.interval(newSchedulerNoti("1", start).Lesson, now) != time.Minute*15/2 {
+		t.Error("Expected the interval to be halved in a rush window")
+	}
+}
+
+func TestSchedulerBudget(t *testing.T) {
+	requests := func(budget int) int {
+		now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+		scheduler := NewScheduler(budget, nil)
+		scheduler.now = func() time.Time {
+			return now
+		}
+
+		// A lesson every day for 5 days, the first one polled every minute
+		notis := make([]database.Noti, 0)
+		for i := 0; i < 5; i++ {
+			notis = append(notis, newSchedulerNoti(fmt.Sprint(i), now.Add(time.Hour*24*time.Duration(i)+time.Minute*59)))
+		}
+
+		// Simulate half an hour of ticks every 30 seconds
+		made := 0
+		for i := 0; i < 60; i++ {
+			made += len(getCheckWindows(scheduler.Due(notis), nil))
+			now = now.Add(time.Second * 30)
+		}
+		return made
+	}
+
+	unlimited := requests(0)
+	limited := requests(20)
+
+	// Half an hour of a budget of 20 per hour, plus checking every day once at the start
+	if limited > 10+5 {
+		t.Errorf("Budget exceeded, made %d requests", limited)
+	}
+
+	if unlimited <= limited {
+		t.Errorf("Budget should lower the amount of requests, made %d unlimited and %d limited", unlimited, limited)
+	}
+}
+
+// countingLessons counts the requests made for lessons
+type countingLessons struct {
+	fakeLessons
+	calls int64
+}
+
+func (c *countingLessons) Availability(ctx context.Context, start uint, end uint, venues []string) ([]fitforfree.Lesson, error) {
+	atomic.AddInt64(&c.calls, 1)
+	return c.fakeLessons.Availability(ctx, start, end, venues)
+}
+
+func TestCheckOnceStaysWithinBudget(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Amsterdam")
+	now := time.Date(2030, 6, 15, 8, 0, 0, 0, loc)
+
+	// Lessons later the same day are polled at other intervals, but they are fetched in the same request
+	lessons := &countingLessons{}
+	watched := make([]database.Noti, 0)
+	for i, start := range []time.Time{
+		now.Add(time.Minute * 50),
+		now.Add(time.Hour * 4),
+		now.Add(time.Hour * 7),
+		now.Add(time.Hour * 15),
+	} {
+		lessons.fakeLessons = append(lessons.fakeLessons, fitforfree.Lesson{ID: fmt.Sprint(i), StartTimestamp: uint(start.Unix()), VenueID: "venue"})
+		watched = append(watched, database.Noti{
+			Model:  gorm.Model{ID: uint(i + 1)},
+			Lesson: database.Lesson{ID: fmt.Sprint(i), Start: uint(start.Unix()), VenueID: "venue"},
+		})
+	}
+
+	budget := 30
+	notis := &fakeNotis{}
+	scheduler := NewScheduler(budget, nil)
+	scheduler.now = func() time.Time {
+		return now
+	}
+	c := New(Config{
+		Lessons:   lessons,
+		Notis:     notis,
+		Format:    formatLessonID{},
+		Scheduler: scheduler,
+	})
+	c.now = scheduler.now
+
+	// An hour of ticks every 30 seconds, the lessons are watched a minute and a half apart
+	for i := 0; i < 120; i++ {
+		if i%3 == 0 && i/3 < len(watched) {
+			notis.notis = append(notis.notis, watched[i/3])
+		}
+
+		if err := c.CheckOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second * 30)
+	}
+
+	// The budget, plus the first check of the day
+	if lessons.calls > int64(budget+1) {
+		t.Errorf("Expected at most %d requests in an hour, made %d", budget+1, lessons.calls)
+	}
+}
@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/gorm"
+)
+
+// dbNotiStore is a NotiStore for the notis in the database
+type dbNotiStore struct {
+	db *gorm.DB
+}
+
+// NewNotiStore returns a NotiStore for the notis in the database
+func NewNotiStore(db *gorm.DB) NotiStore {
+	return dbNotiStore{db: db}
+}
+
+func (s dbNotiStore) Notis(ctx context.Context) ([]database.Noti, error) {
+	return database.NewRepositories(s.db).Notis.All(ctx)
+}
+
+func (s dbNotiStore) Handle(ctx context.Context, handled []Handled, lessons []database.Lesson) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repositories := database.NewRepositories(tx)
+
+		for _, lesson := range lessons {
+			if err := repositories.Lessons.Update(ctx, lesson); err != nil {
+				return err
+			}
+		}
+
+		for _, h := range handled {
+			if h.Message != nil {
+				if err := repositories.Outbox.Add(ctx, h.Message); err != nil {
+					return err
+				}
+			}
+
+			if h.Alert != nil {
+				if err := repositories.Alerts.Record(ctx, h.Alert); err != nil {
+					return err
+				}
+			}
+
+			if h.Retire {
+				if err := repositories.Notis.Delete(ctx, h.Noti.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := repositories.Notis.SaveState(ctx, h.Noti); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s dbNotiStore) Alerts(ctx context.Context, since time.Time) ([]database.Alert, error) {
+	return database.NewRepositories(s.db).Alerts.Since(ctx, since)
+}
+
+// sessionBooker is a Booker that books with the user's own fitforfree session
+type sessionBooker struct {
+	client *fitforfree.Client
+	sealer *database.Sealer
+}
+
+// NewBooker returns a Booker that books with the session of the noti's user, decrypted with sealer
+func NewBooker(client *fitforfree.Client, sealer *database.Sealer) Booker {
+	return sessionBooker{client: client, sealer: sealer}
+}
+
+func (b sessionBooker) Book(ctx context.Context, noti database.Noti) error {
+	token, err := noti.User.Token(b.sealer)
+	if err != nil {
+		return err
+	}
+
+	return b.client.BookLesson(ctx, noti.Lesson.ID, token)
+}
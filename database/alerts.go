@@ -0,0 +1,45 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Alert model, a noti that was alerted of a spot and its place in the order, so admins can see who was alerted first
+type Alert struct {
+	ID          uint   `gorm:"primaryKey"`
+	LessonID    string `gorm:"index"`
+	LessonName  string
+	LessonStart uint
+	NotiID      uint
+	UserID      uint `gorm:"index"`
+	User        User
+	// Policy is the name of the fairness policy that decided the order
+	Policy string
+	// Position is the place of the user in the order, the first user alerted is 0
+	Position int
+	// Spots is the amount of spots available when the lesson was alerted
+	Spots uint8
+	// Delay is how long after the first user this user was alerted
+	Delay time.Duration
+	// Booked is true when the lesson was booked automatically for the user
+	Booked    bool
+	AlertedAt time.Time `gorm:"index"`
+}
+
+// Lost returns if the user was probably too late, alerted after there was a user for every spot and not booked
+func (a Alert) Lost() bool {
+	return !a.Booked && a.Position >= int(a.Spots)
+}
+
+// Alerts returns the alerts since the given time with their user, in the order they were alerted
+func Alerts(db *gorm.DB, since time.Time) ([]Alert, error) {
+	alerts := make([]Alert, 0)
+	err := db.
+		Joins("User").
+		Where("alerts.alerted_at >= ?", since).
+		Order("alerts.alerted_at, alerts.lesson_id, alerts.position").
+		Find(&alerts).Error
+	return alerts, err
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrBackupUnsupported is returned when backing up a database that is not sqlite, use pg_dump for PostgreSQL
+var ErrBackupUnsupported = errors.New("backups are only supported for sqlite databases, use pg_dump for PostgreSQL")
+
+// backupPrefix and backupExt make up the names of backup files with the time they were taken in between
+const (
+	backupPrefix = "database-"
+	backupExt    = ".sqlite"
+	backupLayout = "20060102-150405"
+)
+
+// Backup writes a consistent snapshot of the sqlite database to path while it is in use
+// The snapshot is written next to path first so path is never a half written backup
+func Backup(ctx context.Context, db *gorm.DB, path string) error {
+	if db.Dialector.Name() != "sqlite" {
+		return ErrBackupUnsupported
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("can't create the backup directory: %w", err)
+	}
+
+	// VACUUM INTO refuses to overwrite files
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't remove an old unfinished backup: %w", err)
+	}
+
+	if err := db.WithContext(ctx).Exec("VACUUM INTO ?", tmp).Error; err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("can't back up the database: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("can't move the backup in place: %w", err)
+	}
+	return nil
+}
+
+// restoreNow returns the time the previous database is named after when restoring, overridden in tests
+var restoreNow = time.Now
+
+// Restore replaces the sqlite database at path with the backup at backupPath
+// The backup must be intact and have a schema version the migrations can migrate, older versions are migrated on the next start
+// The current database is kept next to path with .before-restore- and the time appended, its path is returned
+// The bot must not be running while restoring
+func Restore(backupPath string, path string, migrations []Migration) (version uint, previous string, err error) {
+	version, err = checkBackup(backupPath, migrations)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// Never overwrite the database kept by an earlier restore, it may be the only copy of the original
+	previous = path + ".before-restore-" + restoreNow().UTC().Format(backupLayout)
+	if _, err := os.Stat(previous); !os.IsNotExist(err) {
+		return 0, "", fmt.Errorf("can't move the current database aside, %s already exists", previous)
+	}
+
+	// Copy instead of move so the backup itself is kept, and copy next to the database so the swap is a rename
+	restoring := path + ".restore"
+	if err := copyFile(backupPath, restoring); err != nil {
+		os.Remove(restoring)
+		return 0, "", fmt.Errorf("can't copy the backup: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, previous); err != nil {
+			os.Remove(restoring)
+			return 0, "", fmt.Errorf("can't move the current database aside: %w", err)
+		}
+	} else {
+		previous = ""
+	}
+
+	if err := os.Rename(restoring, path); err != nil {
+		return 0, "", fmt.Errorf("can't move the backup in place: %w", err)
+	}
+	return version, previous, nil
+}
+
+// checkBackup returns the schema version of the backup, or an error when it is damaged or newer than the migrations
+func checkBackup(backupPath string, migrations []Migration) (uint, error) {
+	// Opening a file that does not exist would create an empty database
+	if _, err := os.Stat(backupPath); err != nil {
+		return 0, fmt.Errorf("can't read the backup: %w", err)
+	}
+
+	// Open panics on files that are not sqlite databases
+	db, err := gorm.Open(sqlite.Open(backupPath), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return 0, fmt.Errorf("can't open the backup, is it a sqlite database? %w", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var integrity string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&integrity).Error; err != nil {
+		return 0, fmt.Errorf("can't check the backup, is it a sqlite database? %w", err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("the backup is damaged: %s", integrity)
+	}
+
+	migrator := NewMigrator(db, migrations)
+	version, err := migrator.Version()
+	if err != nil {
+		return 0, fmt.Errorf("can't get the schema version of the backup: %w", err)
+	}
+	if version == 0 {
+		return 0, errors.New("the backup has no schema version, it is not a database of the bot")
+	}
+	if version > migrator.Latest() {
+		return 0, fmt.Errorf("the backup is at version %d which is newer than the latest migration %d", version, migrator.Latest())
+	}
+	return version, nil
+}
+
+// copyFile copies the file at from to to, overwriting it
+func copyFile(from string, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Backups takes backups into a directory and removes all but the most recent ones
+type Backups struct {
+	db   *gorm.DB
+	dir  string
+	keep int
+	// mu makes sure scheduled and requested backups don't write at the same time
+	mu sync.Mutex
+	// now returns the current time, overridden in tests
+	now func() time.Time
+}
+
+// NewBackups returns backups of db into dir that keeps the last keep backups, all of them when keep is 0
+func NewBackups(db *gorm.DB, dir string, keep int) *Backups {
+	return &Backups{db: db, dir: dir, keep: keep, now: time.Now}
+}
+
+// Run backs up every interval until the context is done
+func (b *Backups) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := b.BackupOnce(ctx)
+			if err != nil {
+				log.Printf("ERROR: Error backing up the database, err: %+v", err)
+				continue
+			}
+			log.Printf("Backed up the database to %s", path)
+		}
+	}
+}
+
+// BackupOnce takes a backup named after the current time, removes old backups and returns the path of the backup
+func (b *Backups) BackupOnce(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path := filepath.Join(b.dir, backupPrefix+b.now().UTC().Format(backupLayout)+backupExt)
+	if err := Backup(ctx, b.db, path); err != nil {
+		return "", err
+	}
+
+	if err := b.prune(); err != nil {
+		// The backup itself worked
+		log.Printf("ERROR: Error removing old backups, err: %+v", err)
+	}
+	return path, nil
+}
+
+// List returns the paths of the backups in the directory, the oldest first
+func (b *Backups) List() ([]string, error) {
+	entries, err := ioutil.ReadDir(b.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupExt) {
+			continue
+		}
+		paths = append(paths, filepath.Join(b.dir, name))
+	}
+
+	// The names sort by the time they were taken
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// prune removes all but the last keep backups
+func (b *Backups) prune() error {
+	if b.keep == 0 {
+		return nil
+	}
+
+	paths, err := b.List()
+	if err != nil {
+		return err
+	}
+
+	for len(paths) > b.keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
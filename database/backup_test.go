@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "database.sqlite")
+	db := Open(SQLite, path, logger.Discard)
+	if _, err := NewMigrator(db, Migrations).Up(0); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&User{ID: 1, Name: "Anna"})
+
+	backupPath := filepath.Join(dir, "backups", "backup.sqlite")
+	if err := Backup(context.Background(), db, backupPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backupPath + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Expected the unfinished backup to be moved in place")
+	}
+
+	// Changes after the backup are undone by restoring it
+	db.Create(&User{ID: 2, Name: "Bob"})
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	now := time.Date(2030, 6, 15, 12, 0, 0, 0, time.UTC)
+	restoreNow = func() time.Time { return now }
+	defer func() { restoreNow = time.Now }()
+
+	version, previous, err := Restore(backupPath, path, Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != path+".before-restore-20300615-120000" {
+		t.Errorf("Expected the previous database to be named after the time, got %s", previous)
+	}
+	if version != uint(len(Migrations)) {
+		t.Errorf("Expected the backup at version %d, got %d", len(Migrations), version)
+	}
+
+	var count int64
+	Open(SQLite, path, logger.Discard).Model(&User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected the user of the backup only, got %d users", count)
+	}
+
+	Open(SQLite, previous, logger.Discard).Model(&User{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected the previous database to be kept with both users, got %d users", count)
+	}
+
+	// Restoring again at the same time would overwrite the only copy of the original database
+	if _, _, err := Restore(backupPath, path, Migrations); err == nil {
+		t.Error("Expected restoring to fail when the previous database would be overwritten")
+	}
+
+	// A later restore keeps both previous databases
+	now = now.Add(time.Hour)
+	_, second, err := Restore(backupPath, path, Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Open(SQLite, previous, logger.Discard).Model(&User{}).Count(&count)
+	if second == previous || count != 2 {
+		t.Errorf("Expected the first previous database to be kept with both users, got %s and %d users", second, count)
+	}
+
+	if _, err := os.Stat(backupPath); err != nil {
+		t.Errorf("Expected the backup to be kept, got %v", err)
+	}
+}
+
+func TestRestoreChecksBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "database.sqlite")
+	if err := ioutil.WriteFile(path, []byte("current"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A backup made by a newer version of the bot
+	newer := filepath.Join(dir, "newer.sqlite")
+	db := Open(SQLite, newer, logger.Discard)
+	if _, err := NewMigrator(db, Migrations).Up(0); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&SchemaMigration{Version: uint(len(Migrations) + 1), Name: "future", AppliedAt: time.Now()})
+
+	// A sqlite database that is not of the bot
+	other := filepath.Join(dir, "other.sqlite")
+	Open(SQLite, other, logger.Discard).Exec("CREATE TABLE things (id integer)")
+
+	notSQLite := filepath.Join(dir, "text.sqlite")
+	if err := ioutil.WriteFile(notSQLite, []byte("not a database at all, not a database at all"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backup := range []string{newer, other, notSQLite, filepath.Join(dir, "missing.sqlite")} {
+		if _, _, err := Restore(backup, path, Migrations); err == nil {
+			t.Errorf("Expected restoring %s to fail", filepath.Base(backup))
+		}
+	}
+
+	if current, _ := ioutil.ReadFile(path); string(current) != "current" {
+		t.Error("Expected the current database to be untouched when the backup is refused")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.sqlite")); !os.IsNotExist(err) {
+		t.Error("Expected a missing backup not to be created")
+	}
+}
+
+func TestBackupsKeep(t *testing.T) {
+	dir := t.TempDir()
+	db := openTemp(t)
+	db.Create(&User{ID: 1, Name: "Anna"})
+
+	backups := NewBackups(db, dir, 2)
+	now := time.Date(2030, 6, 15, 12, 0, 0, 0, time.UTC)
+	backups.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := backups.BackupOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	paths, err := backups.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "database-20300615-130000.sqlite"),
+		filepath.Join(dir, "database-20300615-140000.sqlite"),
+	}
+	if len(paths) != len(expected) || paths[0] != expected[0] || paths[1] != expected[1] {
+		t.Errorf("Expected the last 2 backups %v, got %v", expected, paths)
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StartedNotis returns the notis whose lesson started before now, with their lesson, user and the user's settings
+func StartedNotis(db *gorm.DB, now time.Time) ([]Noti, error) {
+	notis := make([]Noti, 0)
+	err := db.
+		Joins("Lesson").
+		Joins("User").
+		Preload("User.Settings").
+		Where("lesson_id IN (?)", db.Model(&Lesson{}).Select("id").Where("start <= ?", now.Unix())).
+		Find(&notis).Error
+	return notis, err
+}
+
+// DeleteStartedLessons soft deletes the lessons that started before now and have no notis anymore
+func DeleteStartedLessons(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.
+		Where("start <= ? AND id NOT IN (?)", now.Unix(), db.Model(&Noti{}).Select("lesson_id")).
+		Delete(&Lesson{})
+	return result.RowsAffected, result.Error
+}
+
+// Purge hard deletes the notis and lessons that were soft deleted before the given time
+// Lessons still referenced by a noti, even a soft deleted one, are kept for the foreign key
+func Purge(db *gorm.DB, before time.Time) (notis int64, lessons int64, err error) {
+	result := db.Unscoped().Where("deleted_at < ?", before).Delete(&Noti{})
+	if result.Error != nil {
+		return 0, 0, result.Error
+	}
+	notis = result.RowsAffected
+
+	result = db.Unscoped().
+		Where("deleted_at < ? AND id NOT IN (?)", before, db.Unscoped().Model(&Noti{}).Select("lesson_id").Where("lesson_id IS NOT NULL")).
+		Delete(&Lesson{})
+	if result.Error != nil {
+		return notis, 0, result.Error
+	}
+	return notis, result.RowsAffected, nil
+}
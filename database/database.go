@@ -1,16 +1,23 @@
 package database
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// ErrNoSession is returned when a user has not linked a fitforfree account
+var ErrNoSession = errors.New("user has no fitforfree session")
+
 // User model
 type User struct {
 	gorm.Model
@@ -19,6 +26,117 @@ type User struct {
 	Username string
 	ChatID   uint
 	Notis    []Noti
+	// Session is the user's own fitforfree session, encrypted with a Sealer
+	Session []byte
+	// ExtraVenues are the ids of other venues the user picked, separated by commas
+	ExtraVenues string
+	// Settings are the preferences of the user, the zero value gives the defaults
+	Settings UserSettings
+}
+
+// Venues returns the ids of the venues to show lessons of to the user, the home venue first
+func (u User) Venues() []string {
+	home := u.Settings.HomeVenue
+	if home == "" {
+		home = os.Getenv("VENUE")
+	}
+
+	venues := []string{home}
+	for _, venue := range u.extraVenues() {
+		if venue != home {
+			venues = append(venues, venue)
+		}
+	}
+	return venues
+}
+
+// SetHomeVenue sets the home venue of the user, it still needs to be saved
+func (u *User) SetHomeVenue(venue string) {
+	u.Settings.HomeVenue = venue
+	u.setExtraVenues(removeVenue(u.extraVenues(), venue))
+}
+
+// ToggleExtraVenue adds the venue to the extra venues or removes it when it is one already, it still needs to be saved
+// It returns if the venue is an extra venue now
+func (u *User) ToggleExtraVenue(venue string) bool {
+	extra := u.extraVenues()
+	if without := removeVenue(extra, venue); len(without) != len(extra) {
+		u.setExtraVenues(without)
+		return false
+	}
+
+	u.setExtraVenues(append(extra, venue))
+	return true
+}
+
+func (u User) extraVenues() []string {
+	if u.ExtraVenues == "" {
+		return []string{}
+	}
+	return strings.Split(u.ExtraVenues, ",")
+}
+
+func (u *User) setExtraVenues(venues []string) {
+	u.ExtraVenues = strings.Join(venues, ",")
+}
+
+// removeVenue returns the venues without the given venue
+func removeVenue(venues []string, venue string) []string {
+	without := make([]string, 0, len(venues))
+	for _, v := range venues {
+		if v != venue {
+			without = append(without, v)
+		}
+	}
+	return without
+}
+
+// HasSession returns if the user has linked a fitforfree account
+func (u User) HasSession() bool {
+	return len(u.Session) > 0
+}
+
+// SetSession encrypts the fitforfree session and sets it on the user, it still needs to be saved
+func (u *User) SetSession(sealer *Sealer, session fitforfree.User) error {
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := sealer.Seal(plaintext)
+	if err != nil {
+		return err
+	}
+
+	u.Session = sealed
+	return nil
+}
+
+// FitForFreeSession decrypts the user's fitforfree session, returns ErrNoSession if there is none
+func (u User) FitForFreeSession(sealer *Sealer) (*fitforfree.User, error) {
+	if !u.HasSession() {
+		return nil, ErrNoSession
+	}
+
+	plaintext, err := sealer.Open(u.Session)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt session of user %d: %w", u.ID, err)
+	}
+
+	session := new(fitforfree.User)
+	if err := json.Unmarshal(plaintext, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Token returns the bearer token of the user's fitforfree account, returns ErrNoSession if there is none
+func (u User) Token(sealer *Sealer) (string, error) {
+	session, err := u.FitForFreeSession(sealer)
+	if err != nil {
+		return "", err
+	}
+	return session.SessionID, nil
 }
 
 // Admin returns if the user is an admin
@@ -33,9 +151,23 @@ type Noti struct {
 	User     User
 	LessonID string
 	Lesson   Lesson
+	// AutoBook books the lesson with the user's session when a spot opens
+	AutoBook bool
+	// Watch keeps the noti after alerting, it alerts again when the lesson was full in between
+	Watch bool
+	// AlertedAt is when the watch last alerted, nil if it never did
+	AlertedAt *time.Time
+	// Rearmed is true when the lesson was full since the last alert
+	Rearmed bool
+}
+
+// NotiOptions are the choices a user makes for a noti
+type NotiOptions struct {
+	AutoBook bool
+	Watch    bool
 }
 
-// Lesson model
+// Lesson model, the checker keeps it up to date with the lesson upstream
 type Lesson struct {
 	gorm.Model
 	ID              string `gorm:"primaryKey"`
@@ -43,19 +175,78 @@ type Lesson struct {
 	DurationSeconds uint
 	ClassType       string
 	Name            string
+	Status          string
+	Instructor      string
+	RoomName        string
+	VenueID         string
+	VenueName       string
 }
 
-// New returns a database connection which is migrated acording to the models
-func New(dbPath string, theLogger logger.Interface) *gorm.DB {
-	// Connect to sqlite db and initialize gorm ORM
-	gormDb, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: theLogger,
-	})
-	if err != nil {
+// NewLesson returns the lesson model for a fitforfree lesson
+func NewLesson(lesson fitforfree.Lesson) Lesson {
+	return Lesson{
+		ID:              lesson.ID,
+		Start:           lesson.StartTimestamp,
+		DurationSeconds: lesson.DurationSeconds,
+		ClassType:       lesson.ClassType,
+		Name:            lesson.Activity.Name,
+		Status:          lesson.Status,
+		Instructor:      lesson.Instructor,
+		RoomName:        lesson.RoomName,
+		VenueID:         lesson.VenueID,
+		VenueName:       lesson.VenueName,
+	}
+}
+
+// Drivers of the databases the bot supports
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// DriverFor returns the driver configured, or the driver of the dsn when none is configured
+// postgres:// and postgresql:// urls are PostgreSQL databases, anything else is the path of a SQLite file
+// Use the postgres driver for key=value dsns of PostgreSQL databases
+func DriverFor(configured string, dsn string) (string, error) {
+	switch configured {
+	case SQLite, Postgres:
+		return configured, nil
+	case "":
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			return Postgres, nil
+		}
+		return SQLite, nil
+	default:
+		return "", fmt.Errorf("unknown database driver %q, use %s or %s", configured, SQLite, Postgres)
+	}
+}
+
+// New returns a connection to the database of the driver and dsn which is migrated to the latest migration
+func New(driver string, dsn string, theLogger logger.Interface) *gorm.DB {
+	gormDb := Open(driver, dsn, theLogger)
+
+	if _, err := NewMigrator(gormDb, Migrations).Up(0); err != nil {
 		panic(err)
 	}
 
-	err = gormDb.AutoMigrate(&User{}, &Noti{}, &Lesson{})
+	return gormDb
+}
+
+// Open returns a connection to the database of the driver and dsn without migrating it
+func Open(driver string, dsn string, theLogger logger.Interface) *gorm.DB {
+	var dialector gorm.Dialector
+	switch driver {
+	case SQLite:
+		dialector = sqlite.Open(dsn)
+	case Postgres:
+		dialector = postgres.Open(dsn)
+	default:
+		panic(fmt.Errorf("unknown database driver %q, use %s or %s", driver, SQLite, Postgres))
+	}
+
+	gormDb, err := gorm.Open(dialector, &gorm.Config{
+		Logger: theLogger,
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -63,29 +254,25 @@ func New(dbPath string, theLogger logger.Interface) *gorm.DB {
 	return gormDb
 }
 
-// CreateNoti creates a noti and a lesson if it does not already exist
-func CreateNoti(db *gorm.DB, user User, lesson fitforfree.Lesson) error {
-	l := Lesson{
-		ID:              lesson.ID,
-		Start:           lesson.StartTimestamp,
-		DurationSeconds: lesson.DurationSeconds,
-		ClassType:       lesson.ClassType,
-		Name:            lesson.Activity.Name,
-	}
+// CreateNoti creates a noti and a lesson if it does not already exist, an existing noti gets the given options
+func CreateNoti(db *gorm.DB, user User, lesson fitforfree.Lesson, options NotiOptions) error {
+	l := NewLesson(lesson)
 	db.FirstOrCreate(&l)
 
 	// Check if there is already a noti for this relationship
-	if err := db.Where("lesson_id = ? AND user_id = ?", l.ID, user.ID).First(&Noti{}).Error; err != nil {
+	noti := Noti{}
+	if err := db.Where("lesson_id = ? AND user_id = ?", l.ID, user.ID).First(&noti).Error; err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
 		}
 
 		// Did not find it, create it
-		if err := db.Create(&Noti{UserID: user.ID, LessonID: l.ID}).Error; err != nil {
-			return err
-		}
+		return db.Create(&Noti{UserID: user.ID, LessonID: l.ID, AutoBook: options.AutoBook, Watch: options.Watch}).Error
 	}
 
 	// No error on query so it already exists
-	return nil
+	return db.Model(&noti).Updates(map[string]interface{}{
+		"auto_book": options.AutoBook,
+		"watch":     options.Watch,
+	}).Error
 }
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserVenues(t *testing.T) {
+	os.Setenv("VENUE", "default")
+	user := User{}
+
+	if venues := user.Venues(); !reflect.DeepEqual(venues, []string{"default"}) {
+		t.Errorf("Expected the VENUE environment variable without a home venue, got %v", venues)
+	}
+
+	user.SetHomeVenue("home")
+	if !user.ToggleExtraVenue("a") || !user.ToggleExtraVenue("b") {
+		t.Error("Expected new extra venues to be added")
+	}
+	if venues := user.Venues(); !reflect.DeepEqual(venues, []string{"home", "a", "b"}) {
+		t.Errorf("Expected the home venue and extra venues, got %v", venues)
+	}
+
+	if user.ToggleExtraVenue("a") {
+		t.Error("Expected an existing extra venue to be removed")
+	}
+
+	// Picking an extra venue as home venue removes it from the extra venues
+	user.SetHomeVenue("b")
+	if venues := user.Venues(); !reflect.DeepEqual(venues, []string{"b"}) {
+		t.Errorf("Expected only the new home venue, got %v", venues)
+	}
+}
+
+type quietPayload struct {
+	from  uint8
+	until uint8
+	at    string
+	quiet bool
+	end   string
+}
+
+func TestUserSettingsQuiet(t *testing.T) {
+	payloads := []quietPayload{
+		{from: 0, until: 0, at: "23:30", quiet: false, end: "23:30"},
+		{from: 22, until: 7, at: "23:30", quiet: true, end: "07:00"},
+		{from: 22, until: 7, at: "06:59", quiet: true, end: "07:00"},
+		{from: 22, until: 7, at: "07:00", quiet: false, end: "07:00"},
+		{from: 22, until: 7, at: "12:00", quiet: false, end: "12:00"},
+		{from: 1, until: 8, at: "03:00", quiet: true, end: "08:00"},
+		{from: 1, until: 8, at: "00:30", quiet: false, end: "00:30"},
+	}
+
+	settings := UserSettings{Timezone: "Europe/London"}
+	for _, payload := range payloads {
+		settings.QuietFrom = payload.from
+		settings.QuietUntil = payload.until
+
+		at, err := time.ParseInLocation("15:04 02-01-2006", payload.at+" 15-06-2030", settings.Location())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if quiet := settings.Quiet(at); quiet != payload.quiet {
+			t.Errorf("Expected quiet %t at %s with quiet hours %d to %d", payload.quiet, payload.at, payload.from, payload.until)
+		}
+
+		end := settings.QuietEnd(at)
+		if end.Before(at) || end.In(settings.Location()).Format("15:04") != payload.end {
+			t.Errorf("Expected the quiet hours to end at %s after %s, got %s", payload.end, payload.at, end)
+		}
+	}
+}
+
+func TestUserSettingsDefaults(t *testing.T) {
+	settings := UserSettings{Timezone: "Nowhere/Invalid"}
+
+	if settings.Location().String() != DefaultTimezone {
+		t.Errorf("Expected %s for an invalid timezone, got %s", DefaultTimezone, settings.Location())
+	}
+
+	if settings.Style() != StyleFull {
+		t.Errorf("Expected %s without a notification style, got %s", StyleFull, settings.Style())
+	}
+}
+
+type driverPayload struct {
+	configured string
+	dsn        string
+	driver     string
+	valid      bool
+}
+
+func TestDriverFor(t *testing.T) {
+	payloads := []driverPayload{
+		{configured: "", dsn: "database/database.sqlite", driver: SQLite, valid: true},
+		{configured: "", dsn: "postgres://bot@localhost:5432/bot", driver: Postgres, valid: true},
+		{configured: "", dsn: "postgresql://bot@localhost/bot", driver: Postgres, valid: true},
+		// Paths are never guessed to be PostgreSQL
+		{configured: "", dsn: "data/host=1.sqlite", driver: SQLite, valid: true},
+		{configured: "postgres", dsn: "dbname=bot user=bot", driver: Postgres, valid: true},
+		{configured: "sqlite", dsn: "postgres.sqlite", driver: SQLite, valid: true},
+		{configured: "mysql", dsn: "bot@/bot", valid: false},
+	}
+
+	for _, payload := range payloads {
+		driver, err := DriverFor(payload.configured, payload.dsn)
+		if (err == nil) != payload.valid {
+			t.Errorf("Expected valid %t for driver %q and dsn %q, got %v", payload.valid, payload.configured, payload.dsn, err)
+			continue
+		}
+
+		if driver != payload.driver {
+			t.Errorf("Expected driver %q for driver %q and dsn %q, got %q", payload.driver, payload.configured, payload.dsn, driver)
+		}
+	}
+}
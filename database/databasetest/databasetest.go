@@ -0,0 +1,51 @@
+// Package databasetest runs tests against every database the bot supports
+package databasetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Run runs test on a new SQLite database, and on PostgreSQL when TEST_DATABASE_DSN is set to an empty database
+// The database is migrated to the latest migration before the test and migrated down after it
+// Packages share the PostgreSQL database, so run them one at a time with go test -p 1
+func Run(t *testing.T, test func(t *testing.T, db *gorm.DB)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, open(t, database.SQLite, filepath.Join(t.TempDir(), "test.sqlite")))
+	})
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Log("TEST_DATABASE_DSN is not set, skipping PostgreSQL")
+		return
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		test(t, open(t, database.Postgres, dsn))
+	})
+}
+
+// open returns the migrated database, it is migrated down and closed when the test is done
+func open(t *testing.T, driver string, dsn string) *gorm.DB {
+	db := database.Open(driver, dsn, logger.Discard)
+	migrator := database.NewMigrator(db, database.Migrations)
+
+	t.Cleanup(func() {
+		if _, err := migrator.Down(0); err != nil {
+			t.Error(err)
+		}
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
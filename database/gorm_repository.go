@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewRepositories returns the repositories for the database, which can be SQLite or PostgreSQL
+// Pass a transaction to use the repositories in that transaction
+func NewRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:        gormUsers{db: db},
+		Settings:     gormSettings{db: db},
+		Notis:        gormNotis{db: db},
+		Lessons:      gormLessons{db: db},
+		Observations: gormObservations{db: db},
+		Alerts:       gormAlerts{db: db},
+		Outbox:       gormOutbox{db: db},
+		Cleanup:      gormCleanup{db: db},
+	}
+}
+
+// notFound returns ErrNotFound for gorm's record not found error
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r gormUsers) FirstOrCreate(ctx context.Context, user *User) error {
+	return r.db.WithContext(ctx).Preload("Settings").FirstOrCreate(user).Error
+}
+
+func (r gormUsers) SaveSession(ctx context.Context, user User) error {
+	return r.db.WithContext(ctx).Model(&user).Omit(clause.Associations).Update("session", user.Session).Error
+}
+
+func (r gormUsers) SaveVenues(ctx context.Context, user User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Omit(clause.Associations).Update("extra_venues", user.ExtraVenues).Error; err != nil {
+			return err
+		}
+
+		// The home venue is a setting, the other settings are left as they are
+		settings := user.Settings
+		settings.UserID = user.ID
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"home_venue"}),
+		}).Create(&settings).Error
+	})
+}
+
+type gormSettings struct {
+	db *gorm.DB
+}
+
+func (r gormSettings) Save(ctx context.Context, settings UserSettings) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&settings).Error
+}
+
+type gormNotis struct {
+	db *gorm.DB
+}
+
+func (r gormNotis) Create(ctx context.Context, user User, lesson fitforfree.Lesson, options NotiOptions) error {
+	return CreateNoti(r.db.WithContext(ctx), user, lesson, options)
+}
+
+func (r gormNotis) Get(ctx context.Context, id uint) (Noti, error) {
+	noti := Noti{}
+	err := r.db.WithContext(ctx).First(&noti, id).Error
+	return noti, notFound(err)
+}
+
+func (r gormNotis) All(ctx context.Context) ([]Noti, error) {
+	notis := make([]Noti, 0)
+	err := r.db.WithContext(ctx).Joins("User").Joins("Lesson").Preload("User.Settings").Find(&notis).Error
+	return notis, err
+}
+
+func (r gormNotis) ByUser(ctx context.Context, userID uint) ([]Noti, error) {
+	notis := make([]Noti, 0)
+	err := r.db.WithContext(ctx).Joins("Lesson").Where("notis.user_id = ?", userID).Find(&notis).Error
+	return notis, err
+}
+
+func (r gormNotis) SaveState(ctx context.Context, noti Noti) error {
+	return r.db.WithContext(ctx).Model(&Noti{}).Where("id = ?", noti.ID).Updates(map[string]interface{}{
+		"auto_book":  noti.AutoBook,
+		"alerted_at": noti.AlertedAt,
+		"rearmed":    noti.Rearmed,
+	}).Error
+}
+
+func (r gormNotis) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Noti{}, id).Error
+}
+
+func (r gormNotis) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Noti{}).Error
+}
+
+func (r gormNotis) DeleteByLesson(ctx context.Context, userID uint, lessonID string) error {
+	return r.db.WithContext(ctx).Where("lesson_id = ? AND user_id = ?", lessonID, userID).Delete(&Noti{}).Error
+}
+
+type gormLessons struct {
+	db *gorm.DB
+}
+
+func (r gormLessons) Get(ctx context.Context, id string) (Lesson, error) {
+	lesson := Lesson{}
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&lesson).Error
+	return lesson, notFound(err)
+}
+
+func (r gormLessons) Update(ctx context.Context, lesson Lesson) error {
+	return r.db.WithContext(ctx).Model(&Lesson{}).Where("id = ?", lesson.ID).Updates(map[string]interface{}{
+		"start":            lesson.Start,
+		"duration_seconds": lesson.DurationSeconds,
+		"class_type":       lesson.ClassType,
+		"name":             lesson.Name,
+		"status":           lesson.Status,
+		"instructor":       lesson.Instructor,
+		"room_name":        lesson.RoomName,
+		"venue_id":         lesson.VenueID,
+		"venue_name":       lesson.VenueName,
+	}).Error
+}
+
+type gormObservations struct {
+	db *gorm.DB
+}
+
+func (r gormObservations) Record(ctx context.Context, lessons []fitforfree.Lesson, observedAt time.Time) error {
+	return RecordObservations(r.db.WithContext(ctx), lessons, observedAt)
+}
+
+func (r gormObservations) ByActivity(ctx context.Context, activity string, since time.Time) ([]Observation, error) {
+	return ActivityObservations(r.db.WithContext(ctx), activity, since)
+}
+
+func (r gormObservations) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return PurgeObservations(r.db.WithContext(ctx), before)
+}
+
+type gormAlerts struct {
+	db *gorm.DB
+}
+
+func (r gormAlerts) Record(ctx context.Context, alert *Alert) error {
+	return r.db.WithContext(ctx).Create(alert).Error
+}
+
+func (r gormAlerts) Since(ctx context.Context, since time.Time) ([]Alert, error) {
+	return Alerts(r.db.WithContext(ctx), since)
+}
+
+type gormOutbox struct {
+	db *gorm.DB
+}
+
+func (r gormOutbox) Add(ctx context.Context, message *OutboxMessage) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+func (r gormOutbox) Pending(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	return PendingMessages(r.db.WithContext(ctx), now, limit)
+}
+
+func (r gormOutbox) MarkDelivered(ctx context.Context, message *OutboxMessage, now time.Time) error {
+	return message.MarkDelivered(r.db.WithContext(ctx), now)
+}
+
+func (r gormOutbox) MarkFailed(ctx context.Context, message *OutboxMessage, sendErr error, next time.Time) error {
+	return message.MarkFailed(r.db.WithContext(ctx), sendErr, next)
+}
+
+type gormCleanup struct {
+	db *gorm.DB
+}
+
+func (r gormCleanup) StartedNotis(ctx context.Context, now time.Time) ([]Noti, error) {
+	return StartedNotis(r.db.WithContext(ctx), now)
+}
+
+func (r gormCleanup) Retire(ctx context.Context, noti Noti, message *OutboxMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Noti{}, noti.ID).Error
+	})
+}
+
+func (r gormCleanup) DeleteStartedLessons(ctx context.Context, now time.Time) (int64, error) {
+	return DeleteStartedLessons(r.db.WithContext(ctx), now)
+}
+
+func (r gormCleanup) Purge(ctx context.Context, before time.Time) (int64, int64, error) {
+	return Purge(r.db.WithContext(ctx), before)
+}
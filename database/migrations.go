@@ -0,0 +1,367 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statements are the SQL of a migration for one kind of database, Up applies it and Down reverts it
+type Statements struct {
+	Up   []string
+	Down []string
+}
+
+// Migration is a numbered change to the schema, with the SQL for every kind of database
+type Migration struct {
+	Version  uint
+	Name     string
+	SQLite   Statements
+	Postgres Statements
+}
+
+// Migrations are all migrations in order, a change to a model needs a new migration with the next version
+// Migration 1 is the schema AutoMigrate created before migrations, with the tables added since, so existing databases keep their data
+// Migration 2 adds the columns added to those tables since
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		SQLite: Statements{Up: []string{
+			"CREATE TABLE IF NOT EXISTS `users` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`username` text,`chat_id` integer,PRIMARY KEY (`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`)",
+			"CREATE TABLE IF NOT EXISTS `lessons` (`id` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`start` integer,`duration_seconds` integer,`class_type` text,`name` text,PRIMARY KEY (`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_lessons_deleted_at` ON `lessons`(`deleted_at`)",
+			"CREATE TABLE IF NOT EXISTS `notis` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`user_id` integer,`lesson_id` text,PRIMARY KEY (`id`),CONSTRAINT `fk_notis_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),CONSTRAINT `fk_notis_lesson` FOREIGN KEY (`lesson_id`) REFERENCES `lessons`(`id`),CONSTRAINT `fk_users_notis` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_notis_deleted_at` ON `notis`(`deleted_at`)",
+			"CREATE TABLE IF NOT EXISTS `outbox_messages` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`chat_id` integer,`text` text,`book_lesson_id` text,`attempts` integer,`next_attempt_at` datetime,`last_error` text,`delivered_at` datetime,PRIMARY KEY (`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_outbox_messages_delivered_at` ON `outbox_messages`(`delivered_at`)",
+			"CREATE INDEX IF NOT EXISTS `idx_outbox_messages_next_attempt_at` ON `outbox_messages`(`next_attempt_at`)",
+			"CREATE INDEX IF NOT EXISTS `idx_outbox_messages_deleted_at` ON `outbox_messages`(`deleted_at`)",
+			"CREATE TABLE IF NOT EXISTS `observations` (`id` integer,`lesson_id` text,`activity_name` text,`lesson_start` integer,`observed_at` datetime,`spots_available` integer,`capacity` integer,`availability_percentage` integer,PRIMARY KEY (`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_observations_lesson_id` ON `observations`(`lesson_id`)",
+			"CREATE INDEX IF NOT EXISTS `idx_observations_observed_at` ON `observations`(`observed_at`)",
+			"CREATE INDEX IF NOT EXISTS `idx_observations_activity_name` ON `observations`(`activity_name`)",
+			"CREATE TABLE IF NOT EXISTS `alerts` (`id` integer,`lesson_id` text,`lesson_name` text,`lesson_start` integer,`noti_id` integer,`user_id` integer,`policy` text,`position` integer,`spots` integer,`delay` integer,`booked` numeric,`alerted_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `fk_alerts_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_alerts_alerted_at` ON `alerts`(`alerted_at`)",
+			"CREATE INDEX IF NOT EXISTS `idx_alerts_user_id` ON `alerts`(`user_id`)",
+			"CREATE INDEX IF NOT EXISTS `idx_alerts_lesson_id` ON `alerts`(`lesson_id`)",
+		}, Down: []string{
+			"DROP TABLE `alerts`",
+			"DROP TABLE `observations`",
+			"DROP TABLE `outbox_messages`",
+			"DROP TABLE `notis`",
+			"DROP TABLE `lessons`",
+			"DROP TABLE `users`",
+		}},
+		Postgres: Statements{Up: []string{
+			`CREATE TABLE IF NOT EXISTS "users" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"name" text,"username" text,"chat_id" bigint,PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at")`,
+			`CREATE TABLE IF NOT EXISTS "lessons" ("id" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"start" bigint,"duration_seconds" bigint,"class_type" text,"name" text,PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_lessons_deleted_at" ON "lessons" ("deleted_at")`,
+			`CREATE TABLE IF NOT EXISTS "notis" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" bigint,"lesson_id" text,PRIMARY KEY ("id"),CONSTRAINT "fk_notis_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),CONSTRAINT "fk_notis_lesson" FOREIGN KEY ("lesson_id") REFERENCES "lessons"("id"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_notis_deleted_at" ON "notis" ("deleted_at")`,
+			`CREATE TABLE IF NOT EXISTS "outbox_messages" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"chat_id" bigint,"text" text,"book_lesson_id" text,"attempts" bigint,"next_attempt_at" timestamptz,"last_error" text,"delivered_at" timestamptz,PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_outbox_messages_delivered_at" ON "outbox_messages" ("delivered_at")`,
+			`CREATE INDEX IF NOT EXISTS "idx_outbox_messages_next_attempt_at" ON "outbox_messages" ("next_attempt_at")`,
+			`CREATE INDEX IF NOT EXISTS "idx_outbox_messages_deleted_at" ON "outbox_messages" ("deleted_at")`,
+			`CREATE TABLE IF NOT EXISTS "observations" ("id" bigserial,"lesson_id" text,"activity_name" text,"lesson_start" bigint,"observed_at" timestamptz,"spots_available" smallint,"capacity" smallint,"availability_percentage" smallint,PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_observations_lesson_id" ON "observations" ("lesson_id")`,
+			`CREATE INDEX IF NOT EXISTS "idx_observations_observed_at" ON "observations" ("observed_at")`,
+			`CREATE INDEX IF NOT EXISTS "idx_observations_activity_name" ON "observations" ("activity_name")`,
+			`CREATE TABLE IF NOT EXISTS "alerts" ("id" bigserial,"lesson_id" text,"lesson_name" text,"lesson_start" bigint,"noti_id" bigint,"user_id" bigint,"policy" text,"position" bigint,"spots" smallint,"delay" bigint,"booked" boolean,"alerted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_alerts_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_alerts_alerted_at" ON "alerts" ("alerted_at")`,
+			`CREATE INDEX IF NOT EXISTS "idx_alerts_user_id" ON "alerts" ("user_id")`,
+			`CREATE INDEX IF NOT EXISTS "idx_alerts_lesson_id" ON "alerts" ("lesson_id")`,
+		}, Down: []string{
+			`DROP TABLE "alerts"`,
+			`DROP TABLE "observations"`,
+			`DROP TABLE "outbox_messages"`,
+			`DROP TABLE "notis"`,
+			`DROP TABLE "lessons"`,
+			`DROP TABLE "users"`,
+		}},
+	},
+	{
+		Version: 2,
+		Name:    "user, noti and lesson columns",
+		SQLite: Statements{Up: []string{
+			"ALTER TABLE `users` ADD `session` blob",
+			"ALTER TABLE `users` ADD `home_venue` text",
+			"ALTER TABLE `users` ADD `extra_venues` text",
+			"ALTER TABLE `lessons` ADD `status` text",
+			"ALTER TABLE `lessons` ADD `instructor` text",
+			"ALTER TABLE `lessons` ADD `room_name` text",
+			"ALTER TABLE `lessons` ADD `venue_id` text",
+			"ALTER TABLE `lessons` ADD `venue_name` text",
+			"ALTER TABLE `notis` ADD `auto_book` numeric",
+			"ALTER TABLE `notis` ADD `watch` numeric",
+			"ALTER TABLE `notis` ADD `alerted_at` datetime",
+			"ALTER TABLE `notis` ADD `rearmed` numeric",
+		}, Down: []string{
+			// SQLite can't drop columns, the tables are copied without them
+			"CREATE TABLE `users_old` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`username` text,`chat_id` integer,PRIMARY KEY (`id`))",
+			"INSERT INTO `users_old` SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`name`,`username`,`chat_id` FROM `users`",
+			"DROP TABLE `users`",
+			"ALTER TABLE `users_old` RENAME TO `users`",
+			"CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`)",
+			"CREATE TABLE `lessons_old` (`id` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`start` integer,`duration_seconds` integer,`class_type` text,`name` text,PRIMARY KEY (`id`))",
+			"INSERT INTO `lessons_old` SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`start`,`duration_seconds`,`class_type`,`name` FROM `lessons`",
+			"DROP TABLE `lessons`",
+			"ALTER TABLE `lessons_old` RENAME TO `lessons`",
+			"CREATE INDEX `idx_lessons_deleted_at` ON `lessons`(`deleted_at`)",
+			"CREATE TABLE `notis_old` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`user_id` integer,`lesson_id` text,PRIMARY KEY (`id`),CONSTRAINT `fk_notis_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),CONSTRAINT `fk_notis_lesson` FOREIGN KEY (`lesson_id`) REFERENCES `lessons`(`id`),CONSTRAINT `fk_users_notis` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+			"INSERT INTO `notis_old` SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`user_id`,`lesson_id` FROM `notis`",
+			"DROP TABLE `notis`",
+			"ALTER TABLE `notis_old` RENAME TO `notis`",
+			"CREATE INDEX `idx_notis_deleted_at` ON `notis`(`deleted_at`)",
+		}},
+		Postgres: Statements{Up: []string{
+			`ALTER TABLE "users" ADD "session" bytea`,
+			`ALTER TABLE "users" ADD "home_venue" text`,
+			`ALTER TABLE "users" ADD "extra_venues" text`,
+			`ALTER TABLE "lessons" ADD "status" text`,
+			`ALTER TABLE "lessons" ADD "instructor" text`,
+			`ALTER TABLE "lessons" ADD "room_name" text`,
+			`ALTER TABLE "lessons" ADD "venue_id" text`,
+			`ALTER TABLE "lessons" ADD "venue_name" text`,
+			`ALTER TABLE "notis" ADD "auto_book" boolean`,
+			`ALTER TABLE "notis" ADD "watch" boolean`,
+			`ALTER TABLE "notis" ADD "alerted_at" timestamptz`,
+			`ALTER TABLE "notis" ADD "rearmed" boolean`,
+		}, Down: []string{
+			`ALTER TABLE "users" DROP COLUMN "session", DROP COLUMN "home_venue", DROP COLUMN "extra_venues"`,
+			`ALTER TABLE "lessons" DROP COLUMN "status", DROP COLUMN "instructor", DROP COLUMN "room_name", DROP COLUMN "venue_id", DROP COLUMN "venue_name"`,
+			`ALTER TABLE "notis" DROP COLUMN "auto_book", DROP COLUMN "watch", DROP COLUMN "alerted_at", DROP COLUMN "rearmed"`,
+		}},
+	},
+	{
+		Version: 3,
+		Name:    "user settings",
+		SQLite: Statements{Up: []string{
+			"CREATE TABLE `user_settings` (`user_id` integer,`language` text,`timezone` text,`quiet_from` integer,`quiet_until` integer,`class_type` text,`notification_style` text,PRIMARY KEY (`user_id`),CONSTRAINT `fk_users_settings` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+			"ALTER TABLE `outbox_messages` ADD `silent` numeric",
+			"ALTER TABLE `outbox_messages` ADD `language` text",
+		}, Down: []string{
+			"DROP TABLE `user_settings`",
+			// SQLite can't drop columns, the table is copied without them
+			"CREATE TABLE `outbox_messages_old` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`chat_id` integer,`text` text,`book_lesson_id` text,`attempts` integer,`next_attempt_at` datetime,`last_error` text,`delivered_at` datetime,PRIMARY KEY (`id`))",
+			"INSERT INTO `outbox_messages_old` SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`chat_id`,`text`,`book_lesson_id`,`attempts`,`next_attempt_at`,`last_error`,`delivered_at` FROM `outbox_messages`",
+			"DROP TABLE `outbox_messages`",
+			"ALTER TABLE `outbox_messages_old` RENAME TO `outbox_messages`",
+			"CREATE INDEX `idx_outbox_messages_delivered_at` ON `outbox_messages`(`delivered_at`)",
+			"CREATE INDEX `idx_outbox_messages_next_attempt_at` ON `outbox_messages`(`next_attempt_at`)",
+			"CREATE INDEX `idx_outbox_messages_deleted_at` ON `outbox_messages`(`deleted_at`)",
+		}},
+		Postgres: Statements{Up: []string{
+			`CREATE TABLE "user_settings" ("user_id" bigint,"language" text,"timezone" text,"quiet_from" smallint,"quiet_until" smallint,"class_type" text,"notification_style" text,PRIMARY KEY ("user_id"),CONSTRAINT "fk_users_settings" FOREIGN KEY ("user_id") REFERENCES "users"("id"))`,
+			`ALTER TABLE "outbox_messages" ADD "silent" boolean`,
+			`ALTER TABLE "outbox_messages" ADD "language" text`,
+		}, Down: []string{
+			`DROP TABLE "user_settings"`,
+			`ALTER TABLE "outbox_messages" DROP COLUMN "silent"`,
+			`ALTER TABLE "outbox_messages" DROP COLUMN "language"`,
+		}},
+	},
+	{
+		Version: 4,
+		Name:    "home venue setting",
+		SQLite: Statements{Up: []string{
+			"ALTER TABLE `user_settings` ADD `home_venue` text",
+			"INSERT INTO `user_settings` (`user_id`,`language`,`timezone`,`quiet_from`,`quiet_until`,`class_type`,`notification_style`) SELECT `id`,'','',0,0,'','' FROM `users` WHERE `home_venue` <> '' AND `id` NOT IN (SELECT `user_id` FROM `user_settings`)",
+			"UPDATE `user_settings` SET `home_venue` = COALESCE((SELECT `home_venue` FROM `users` WHERE `users`.`id` = `user_settings`.`user_id`), '')",
+			// SQLite can't drop columns, the table is copied without it
+			"CREATE TABLE `users_new` (`id` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text,`username` text,`chat_id` integer,`session` blob,`extra_venues` text,PRIMARY KEY (`id`))",
+			"INSERT INTO `users_new` SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`name`,`username`,`chat_id`,`session`,`extra_venues` FROM `users`",
+			"DROP TABLE `users`",
+			"ALTER TABLE `users_new` RENAME TO `users`",
+			"CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`)",
+		}, Down: []string{
+			"ALTER TABLE `users` ADD `home_venue` text",
+			"UPDATE `users` SET `home_venue` = (SELECT `home_venue` FROM `user_settings` WHERE `user_settings`.`user_id` = `users`.`id`)",
+			// SQLite can't drop columns, the table is copied without it
+			"CREATE TABLE `user_settings_old` (`user_id` integer,`language` text,`timezone` text,`quiet_from` integer,`quiet_until` integer,`class_type` text,`notification_style` text,PRIMARY KEY (`user_id`),CONSTRAINT `fk_users_settings` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+			"INSERT INTO `user_settings_old` SELECT `user_id`,`language`,`timezone`,`quiet_from`,`quiet_until`,`class_type`,`notification_style` FROM `user_settings`",
+			"DROP TABLE `user_settings`",
+			"ALTER TABLE `user_settings_old` RENAME TO `user_settings`",
+		}},
+		Postgres: Statements{Up: []string{
+			`ALTER TABLE "user_settings" ADD "home_venue" text`,
+			`INSERT INTO "user_settings" ("user_id","language","timezone","quiet_from","quiet_until","class_type","notification_style") SELECT "id",'','',0,0,'','' FROM "users" WHERE "home_venue" <> '' AND "id" NOT IN (SELECT "user_id" FROM "user_settings")`,
+			`UPDATE "user_settings" SET "home_venue" = COALESCE((SELECT "home_venue" FROM "users" WHERE "users"."id" = "user_settings"."user_id"), '')`,
+			`ALTER TABLE "users" DROP COLUMN "home_venue"`,
+		}, Down: []string{
+			`ALTER TABLE "users" ADD "home_venue" text`,
+			`UPDATE "users" SET "home_venue" = (SELECT "home_venue" FROM "user_settings" WHERE "user_settings"."user_id" = "users"."id")`,
+			`ALTER TABLE "user_settings" DROP COLUMN "home_venue"`,
+		}},
+	},
+}
+
+// SchemaMigration model, a migration that was applied to the database
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts migrations, every migration in its own transaction
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	// DryRun prints the SQL of the migrations to Out instead of running it
+	DryRun bool
+	Out    io.Writer
+}
+
+// NewMigrator returns a migrator for the migrations, which should be sorted by version
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest returns the version of the last migration
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the last migration applied to the database, 0 when there is none
+func (m *Migrator) Version() (uint, error) {
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+
+	var version uint
+	err := m.db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Up applies the migrations up to and including target, all of them when target is 0
+// It returns the migrations that were applied
+func (m *Migrator) Up(target uint) ([]Migration, error) {
+	if target == 0 {
+		target = m.Latest()
+	}
+
+	current, err := m.prepare(target)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, migration := range m.migrations {
+		if migration.Version <= current || migration.Version > target {
+			continue
+		}
+
+		record := func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		}
+		if err := m.run(migration, "up", m.statements(migration).Up, record); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the migrations after target, in reverse order
+// It returns the migrations that were reverted
+func (m *Migrator) Down(target uint) ([]Migration, error) {
+	current, err := m.prepare(target)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make([]Migration, 0)
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version > current || migration.Version <= target {
+			continue
+		}
+
+		forget := func(tx *gorm.DB) error {
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		}
+		if err := m.run(migration, "down", m.statements(migration).Down, forget); err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// statements returns the SQL of the migration for the kind of database the migrator migrates
+func (m *Migrator) statements(migration Migration) Statements {
+	if m.db.Dialector.Name() == "postgres" {
+		return migration.Postgres
+	}
+	return migration.SQLite
+}
+
+// prepare checks the migrations and target, creates the schema_migrations table and returns the current version
+func (m *Migrator) prepare(target uint) (uint, error) {
+	if name := m.db.Dialector.Name(); name != "sqlite" && name != "postgres" {
+		return 0, fmt.Errorf("can't migrate %s databases", name)
+	}
+
+	for i, migration := range m.migrations {
+		if migration.Version != uint(i+1) {
+			return 0, fmt.Errorf("migration %q has version %d, expected %d", migration.Name, migration.Version, i+1)
+		}
+	}
+
+	if target > m.Latest() {
+		return 0, fmt.Errorf("there is no migration %d, the latest is %d", target, m.Latest())
+	}
+
+	current, err := m.Version()
+	if err != nil {
+		return 0, fmt.Errorf("can't get the schema version: %w", err)
+	}
+
+	if current > m.Latest() {
+		return 0, fmt.Errorf("the database is at version %d which is newer than the latest migration %d", current, m.Latest())
+	}
+
+	if !m.DryRun {
+		if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+			return 0, fmt.Errorf("can't create the schema_migrations table: %w", err)
+		}
+	}
+
+	return current, nil
+}
+
+// run runs the statements of the migration and updates schema_migrations with track in one transaction
+func (m *Migrator) run(migration Migration, direction string, statements []string, track func(tx *gorm.DB) error) error {
+	if m.DryRun {
+		fmt.Fprintf(m.Out, "-- %d %s (%s)\n", migration.Version, migration.Name, direction)
+		for _, statement := range statements {
+			fmt.Fprintf(m.Out, "%s;\n", statement)
+		}
+		return nil
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return track(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("can't migrate %s %d %s: %w", direction, migration.Version, migration.Name, err)
+	}
+
+	log.Printf("Migrated %s %d %s", direction, migration.Version, migration.Name)
+	return nil
+}
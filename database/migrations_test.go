@@ -0,0 +1,264 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTemp(t *testing.T) *gorm.DB {
+	return Open(SQLite, filepath.Join(t.TempDir(), "test.sqlite"), logger.Discard)
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	db := openTemp(t)
+	if _, err := NewMigrator(db, Migrations).Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, model := range []interface{}{&User{}, &Noti{}, &Lesson{}, &OutboxMessage{}, &Observation{}, &Alert{}, &UserSettings{}} {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, field := range statement.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Column %s of %s is not created by the migrations", field.DBName, statement.Schema.Table)
+			}
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db := openTemp(t)
+
+	// Existing databases were created by AutoMigrate with the schema of the first migration, their data is kept
+	for _, statement := range Migrations[0].SQLite.Up {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Exec("INSERT INTO users (id, name) VALUES (1, 'Anna')")
+
+	migrations := append(append([]Migration{}, Migrations...), Migration{
+		Version: uint(len(Migrations) + 1),
+		Name:    "rename user name",
+		SQLite: Statements{
+			Up:   []string{"ALTER TABLE users RENAME COLUMN name TO full_name"},
+			Down: []string{"ALTER TABLE users RENAME COLUMN full_name TO name"},
+		},
+		Postgres: Statements{
+			Up:   []string{"ALTER TABLE users RENAME COLUMN name TO full_name"},
+			Down: []string{"ALTER TABLE users RENAME COLUMN full_name TO name"},
+		},
+	})
+	migrator := NewMigrator(db, migrations)
+
+	applied, err := migrator.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Expected %d migrations to be applied, got %d", len(migrations), len(applied))
+	}
+
+	if version, _ := migrator.Version(); version != migrator.Latest() {
+		t.Errorf("Expected version %d, got %d", migrator.Latest(), version)
+	}
+
+	var name string
+	db.Raw("SELECT full_name FROM users WHERE id = 1").Scan(&name)
+	if name != "Anna" {
+		t.Errorf("Expected the renamed column to keep its data, got %q", name)
+	}
+
+	// Applying again does nothing
+	if applied, err := migrator.Up(0); err != nil || len(applied) != 0 {
+		t.Errorf("Expected nothing to be applied, got %d migrations and error %v", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(uint(len(Migrations)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || !db.Migrator().HasColumn(&User{}, "name") {
+		t.Errorf("Expected the rename to be reverted, got %d migrations", len(reverted))
+	}
+
+	if _, err := migrator.Down(0); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasTable(&User{}) {
+		t.Error("Expected every table to be dropped")
+	}
+	if version, _ := migrator.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %d", version)
+	}
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	db := openTemp(t)
+
+	out := &bytes.Buffer{}
+	migrator := NewMigrator(db, Migrations)
+	migrator.DryRun = true
+	migrator.Out = out
+
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "-- 1 initial schema (up)") || !strings.Contains(out.String(), "CREATE TABLE IF NOT EXISTS `users`") {
+		t.Errorf("Expected the SQL of the initial schema, got %s", out.String())
+	}
+
+	if db.Migrator().HasTable(&User{}) || db.Migrator().HasTable(&SchemaMigration{}) {
+		t.Error("A dry run should not change the database")
+	}
+}
+
+func TestMigratorErrors(t *testing.T) {
+	db := openTemp(t)
+
+	if _, err := NewMigrator(db, []Migration{{Version: 2}}).Up(0); err == nil {
+		t.Error("Expected an error for migrations that are not numbered in order")
+	}
+
+	if _, err := NewMigrator(db, Migrations).Up(uint(len(Migrations) + 1)); err == nil {
+		t.Error("Expected an error for a target without migration")
+	}
+
+	// A database migrated by a newer version of the program can't be migrated
+	db.AutoMigrate(&SchemaMigration{})
+	db.Create(&SchemaMigration{Version: 100})
+	if _, err := NewMigrator(db, Migrations).Up(0); err == nil {
+		t.Error("Expected an error for a database newer than the migrations")
+	}
+}
+
+// baselineUser, baselineNoti and baselineLesson are the models of the first release, which created its tables with AutoMigrate
+type baselineUser struct {
+	gorm.Model
+	ID       uint `gorm:"primaryKey"`
+	Name     string
+	Username string
+	ChatID   uint
+	Notis    []baselineNoti `gorm:"foreignKey:UserID"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineNoti struct {
+	gorm.Model
+	UserID   uint
+	User     baselineUser `gorm:"foreignKey:UserID"`
+	LessonID string
+	Lesson   baselineLesson `gorm:"foreignKey:LessonID"`
+}
+
+func (baselineNoti) TableName() string { return "notis" }
+
+type baselineLesson struct {
+	gorm.Model
+	ID              string `gorm:"primaryKey"`
+	Start           uint
+	DurationSeconds uint
+	ClassType       string
+	Name            string
+}
+
+func (baselineLesson) TableName() string { return "lessons" }
+
+func TestMigrateBaseline(t *testing.T) {
+	db := openTemp(t)
+	if err := db.AutoMigrate(&baselineUser{}, &baselineNoti{}, &baselineLesson{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&baselineUser{ID: 1, Name: "Anna", ChatID: 10})
+	db.Create(&baselineLesson{ID: "les", Start: 1000, Name: "Spinning"})
+	db.Create(&baselineNoti{UserID: 1, LessonID: "les"})
+
+	if _, err := NewMigrator(db, Migrations).Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	repositories := NewRepositories(db)
+	ctx := context.Background()
+
+	notis, err := repositories.Notis.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notis) != 1 || notis[0].User.Name != "Anna" || notis[0].Lesson.Name != "Spinning" {
+		t.Errorf("Expected the noti of the first release with its user and lesson, got %+v", notis)
+	}
+
+	user := notis[0].User
+	user.SetHomeVenue("home")
+	user.ToggleExtraVenue("extra")
+	if err := repositories.Users.SaveVenues(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, model := range []interface{}{&User{}, &Noti{}, &Lesson{}} {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, field := range statement.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Column %s of %s is not added to the tables of the first release", field.DBName, statement.Schema.Table)
+			}
+		}
+	}
+}
+
+func TestMigrateHomeVenue(t *testing.T) {
+	db := openTemp(t)
+	migrator := NewMigrator(db, Migrations)
+
+	// Before version 4 the home venue was a column of the user
+	if _, err := migrator.Up(3); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO users (id, name, home_venue) VALUES (1, 'Anna', 'home'), (2, 'Bram', 'other'), (3, 'Cas', '')")
+	db.Exec("INSERT INTO user_settings (user_id, language, timezone, quiet_from, quiet_until, class_type, notification_style) VALUES (2, 'en', '', 0, 0, '', '')")
+
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[uint]string{1: "home", 2: "other", 3: ""}
+	for id, venue := range expected {
+		user := User{}
+		if err := db.Preload("Settings").First(&user, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if user.Settings.HomeVenue != venue {
+			t.Errorf("Expected home venue %q of user %d to be moved to the settings, got %q", venue, id, user.Settings.HomeVenue)
+		}
+	}
+
+	settings := UserSettings{}
+	db.Where("user_id = ?", 2).First(&settings)
+	if settings.Language != "en" {
+		t.Errorf("Expected the other settings of user 2 to be kept, got %+v", settings)
+	}
+
+	if _, err := migrator.Down(3); err != nil {
+		t.Fatal(err)
+	}
+
+	var venue string
+	db.Raw("SELECT home_venue FROM users WHERE id = 1").Scan(&venue)
+	if venue != "home" {
+		t.Errorf("Expected the home venue to be moved back to the user, got %q", venue)
+	}
+}
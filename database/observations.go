@@ -0,0 +1,251 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/gorm"
+)
+
+// Observation model, the availability of a lesson at one moment
+type Observation struct {
+	ID                     uint   `gorm:"primaryKey"`
+	LessonID               string `gorm:"index"`
+	ActivityName           string `gorm:"index"`
+	LessonStart            uint
+	ObservedAt             time.Time `gorm:"index"`
+	SpotsAvailable         uint8
+	Capacity               uint8
+	AvailabilityPercentage uint8
+}
+
+// RecordObservations stores the availability of the lessons as observed at observedAt
+// Lessons whose spots, capacity and start did not change since their last observation are not stored again
+func RecordObservations(db *gorm.DB, lessons []fitforfree.Lesson, observedAt time.Time) error {
+	if len(lessons) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(lessons))
+	for _, lesson := range lessons {
+		ids = append(ids, lesson.ID)
+	}
+
+	latest := make([]Observation, 0)
+	err := db.
+		Where("id IN (?)", db.Model(&Observation{}).Select("MAX(id)").Where("lesson_id IN ?", ids).Group("lesson_id")).
+		Find(&latest).Error
+	if err != nil {
+		return err
+	}
+
+	last := make(map[string]Observation, len(latest))
+	for _, observation := range latest {
+		last[observation.LessonID] = observation
+	}
+
+	observations := make([]Observation, 0, len(lessons))
+	for _, lesson := range lessons {
+		previous, seen := last[lesson.ID]
+		if seen && previous.SpotsAvailable == lesson.SpotsAvailable && previous.Capacity == lesson.Capacity && previous.LessonStart == lesson.StartTimestamp {
+			continue
+		}
+
+		observations = append(observations, Observation{
+			LessonID:               lesson.ID,
+			ActivityName:           lesson.Activity.Name,
+			LessonStart:            lesson.StartTimestamp,
+			ObservedAt:             observedAt,
+			SpotsAvailable:         lesson.SpotsAvailable,
+			Capacity:               lesson.Capacity,
+			AvailabilityPercentage: lesson.AvailabilityPercentage,
+		})
+	}
+
+	if len(observations) == 0 {
+		return nil
+	}
+	return db.Create(&observations).Error
+}
+
+// Opening is a period in which a lesson that was full had spots available again
+type Opening struct {
+	LessonID    string
+	LessonStart uint
+	OpenedAt    time.Time
+	// ClosedAt is when the lesson was seen full again, nil when it was not
+	ClosedAt *time.Time
+	// Spots is the most spots seen available during the opening
+	Spots uint8
+}
+
+// Lead returns how long before the start of the lesson the spots opened
+func (o Opening) Lead() time.Duration {
+	return time.Unix(int64(o.LessonStart), 0).Sub(o.OpenedAt)
+}
+
+// Duration returns how long the spots were available, false when the lesson was not seen full again
+func (o Opening) Duration() (time.Duration, bool) {
+	if o.ClosedAt == nil {
+		return 0, false
+	}
+	return o.ClosedAt.Sub(o.OpenedAt), true
+}
+
+// OpeningStats summarizes openings
+type OpeningStats struct {
+	Openings int
+	// MedianLead is the median time before the start of the lesson spots opened
+	MedianLead time.Duration
+	// MedianDuration is the median time spots stayed available, of the openings that closed
+	MedianDuration time.Duration
+}
+
+// CancellationStats counts the spots that opened shortly before lessons
+type CancellationStats struct {
+	Lessons       int
+	Cancellations int
+}
+
+// PerLesson returns the average amount of cancellations per lesson
+func (s CancellationStats) PerLesson() float64 {
+	if s.Lessons == 0 {
+		return 0
+	}
+	return float64(s.Cancellations) / float64(s.Lessons)
+}
+
+// Openings returns every time a full lesson of the activity got spots available, of lessons starting after since
+func Openings(db *gorm.DB, activity string, since time.Time) ([]Opening, error) {
+	observations, err := ActivityObservations(db, activity, since)
+	if err != nil {
+		return nil, err
+	}
+	return FindOpenings(observations), nil
+}
+
+// SummarizeOpenings returns when spots usually open and how long they last
+func SummarizeOpenings(openings []Opening) OpeningStats {
+	leads := make([]time.Duration, 0, len(openings))
+	durations := make([]time.Duration, 0, len(openings))
+	for _, opening := range openings {
+		leads = append(leads, opening.Lead())
+		if duration, closed := opening.Duration(); closed {
+			durations = append(durations, duration)
+		}
+	}
+
+	return OpeningStats{
+		Openings:       len(openings),
+		MedianLead:     median(leads),
+		MedianDuration: median(durations),
+	}
+}
+
+// Cancellations counts the spots that opened within before the start of lessons of the activity starting after since
+// Every increase in spots available between 2 observations counts as that many cancellations
+func Cancellations(db *gorm.DB, activity string, before time.Duration, since time.Time) (CancellationStats, error) {
+	observations, err := ActivityObservations(db, activity, since)
+	if err != nil {
+		return CancellationStats{}, err
+	}
+
+	stats := CancellationStats{}
+	lessons := make(map[string]bool)
+	for i, observation := range observations {
+		start := time.Unix(int64(observation.LessonStart), 0)
+		if observation.ObservedAt.Before(start.Add(-before)) || !observation.ObservedAt.Before(start) {
+			continue
+		}
+		lessons[observation.LessonID] = true
+
+		if i == 0 || observations[i-1].LessonID != observation.LessonID {
+			continue
+		}
+
+		if previous := observations[i-1].SpotsAvailable; observation.SpotsAvailable > previous {
+			stats.Cancellations += int(observation.SpotsAvailable - previous)
+		}
+	}
+	stats.Lessons = len(lessons)
+
+	return stats, nil
+}
+
+// PurgeObservations deletes the observations of lessons that started before the given time
+func PurgeObservations(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("lesson_start < ?", before.Unix()).Delete(&Observation{})
+	return result.RowsAffected, result.Error
+}
+
+// ActivityObservations returns the observations of lessons of the activity starting after since, by lesson and time
+func ActivityObservations(db *gorm.DB, activity string, since time.Time) ([]Observation, error) {
+	observations := make([]Observation, 0)
+	err := db.
+		Where("activity_name = ? AND lesson_start >= ?", activity, since.Unix()).
+		Order("lesson_id, observed_at").
+		Find(&observations).Error
+	return observations, err
+}
+
+// FindOpenings returns the openings in observations sorted by lesson and time, observations after the start are ignored
+func FindOpenings(observations []Observation) []Opening {
+	openings := make([]Opening, 0)
+
+	// current is the index of the opening that is still open, -1 when there is none
+	current := -1
+	wasFull := false
+	for i, observation := range observations {
+		if i > 0 && observations[i-1].LessonID != observation.LessonID {
+			current = -1
+			wasFull = false
+		}
+
+		if observation.ObservedAt.Unix() >= int64(observation.LessonStart) {
+			continue
+		}
+
+		switch {
+		case observation.SpotsAvailable == 0:
+			if current != -1 {
+				closedAt := observation.ObservedAt
+				openings[current].ClosedAt = &closedAt
+				current = -1
+			}
+			wasFull = true
+		case current != -1:
+			if observation.SpotsAvailable > openings[current].Spots {
+				openings[current].Spots = observation.SpotsAvailable
+			}
+		case wasFull:
+			openings = append(openings, Opening{
+				LessonID:    observation.LessonID,
+				LessonStart: observation.LessonStart,
+				OpenedAt:    observation.ObservedAt,
+				Spots:       observation.SpotsAvailable,
+			})
+			current = len(openings) - 1
+		}
+	}
+
+	return openings
+}
+
+// median returns the median of the durations, 0 when there are none
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
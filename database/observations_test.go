@@ -0,0 +1,145 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestObservations(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&Observation{}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+	observe := func(id string, activity string, spots uint8, before time.Duration) {
+		lesson := fitforfree.Lesson{
+			ID:             id,
+			Activity:       fitforfree.Activity{Name: activity},
+			StartTimestamp: uint(start.Unix()),
+			SpotsAvailable: spots,
+			Capacity:       20,
+		}
+		if err := RecordObservations(db, []fitforfree.Lesson{lesson}, start.Add(-before)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Lesson 1 is full, opens 5 hours before for an hour, opens again 1 hour before and stays open
+	observe("1", "Spinning", 0, time.Hour*24)
+	observe("1", "Spinning", 2, time.Hour*5)
+	observe("1", "Spinning", 1, time.Hour*4+time.Minute*30)
+	observe("1", "Spinning", 0, time.Hour*4)
+	observe("1", "Spinning", 3, time.Hour)
+	observe("1", "Spinning", 3, -time.Minute)
+
+	// Lesson 2 is never full, so spots never opened
+	observe("2", "Spinning", 5, time.Hour*24)
+	observe("2", "Spinning", 6, time.Hour)
+
+	// Other activities are not counted
+	observe("3", "Yoga", 0, time.Hour*24)
+	observe("3", "Yoga", 1, time.Hour)
+
+	openings, err := Openings(db, "Spinning", start.Add(-time.Hour*48))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(openings) != 2 {
+		t.Fatalf("Expected 2 openings, got %+v", openings)
+	}
+
+	if openings[0].Lead() != time.Hour*5 || openings[0].Spots != 2 {
+		t.Errorf("Expected the first opening 5 hours before with 2 spots, got %+v", openings[0])
+	}
+
+	if duration, closed := openings[0].Duration(); !closed || duration != time.Hour {
+		t.Errorf("Expected the first opening to last an hour, got %s", duration)
+	}
+
+	if _, closed := openings[1].Duration(); closed {
+		t.Error("Expected the second opening to stay open")
+	}
+
+	stats := SummarizeOpenings(openings)
+	if stats.Openings != 2 || stats.MedianLead != time.Hour*3 || stats.MedianDuration != time.Hour {
+		t.Errorf("Expected a median lead of 3 hours and duration of 1 hour, got %+v", stats)
+	}
+
+	cancellations, err := Cancellations(db, "Spinning", time.Hour*6, start.Add(-time.Hour*48))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lesson 1 got 2 and 3 spots, lesson 2 got 1 spot
+	if cancellations.Lessons != 2 || cancellations.Cancellations != 6 || cancellations.PerLesson() != 3 {
+		t.Errorf("Expected 6 cancellations in 2 lessons, got %+v", cancellations)
+	}
+}
+
+func TestRecordObservationsOnlyChanges(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&Observation{}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+	fetch := func(minute int, spots uint8, capacity uint8) {
+		lessons := []fitforfree.Lesson{
+			{ID: "changing", StartTimestamp: uint(start.Unix()), SpotsAvailable: spots, Capacity: capacity},
+			{ID: "same", StartTimestamp: uint(start.Unix()), SpotsAvailable: 5, Capacity: 20},
+		}
+		if err := RecordObservations(db, lessons, start.Add(-time.Hour+time.Minute*time.Duration(minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fetched every minute, the spots change twice and the capacity once
+	fetch(0, 0, 20)
+	fetch(1, 0, 20)
+	fetch(2, 1, 20)
+	fetch(3, 1, 20)
+	fetch(4, 1, 25)
+	fetch(5, 0, 25)
+	fetch(6, 0, 25)
+
+	observations := make([]Observation, 0)
+	db.Order("lesson_id, observed_at").Find(&observations)
+
+	expected := []struct {
+		lesson   string
+		minute   int
+		spots    uint8
+		capacity uint8
+	}{
+		{lesson: "changing", minute: 0, spots: 0, capacity: 20},
+		{lesson: "changing", minute: 2, spots: 1, capacity: 20},
+		{lesson: "changing", minute: 4, spots: 1, capacity: 25},
+		{lesson: "changing", minute: 5, spots: 0, capacity: 25},
+		{lesson: "same", minute: 0, spots: 5, capacity: 20},
+	}
+	if len(observations) != len(expected) {
+		t.Fatalf("Expected %d observations of the changes, got %+v", len(expected), observations)
+	}
+
+	for i, e := range expected {
+		observation := observations[i]
+		observedAt := start.Add(-time.Hour + time.Minute*time.Duration(e.minute))
+		if observation.LessonID != e.lesson || !observation.ObservedAt.Equal(observedAt) || observation.SpotsAvailable != e.spots || observation.Capacity != e.capacity {
+			t.Errorf("Expected %s with %d of %d spots at minute %d, got %+v", e.lesson, e.spots, e.capacity, e.minute, observation)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxMessage model, a message to a user that is sent until telegram accepts it
+type OutboxMessage struct {
+	gorm.Model
+	ChatID int64
+	Text   string
+	// BookLessonID adds a button to book this lesson, empty for no button
+	BookLessonID string
+	// Attempts is the amount of failed attempts to send the message
+	Attempts uint
+	// NextAttemptAt is when to try sending again, new messages are sent right away
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	// DeliveredAt is set once telegram accepted the message
+	DeliveredAt *time.Time `gorm:"index"`
+	// Silent sends the message without sound
+	Silent bool
+	// Language of the buttons, empty for dutch
+	Language string
+}
+
+// PendingMessages returns at most limit undelivered messages that should be attempted at now, oldest first
+func PendingMessages(db *gorm.DB, now time.Time, limit int) ([]OutboxMessage, error) {
+	messages := make([]OutboxMessage, 0)
+	err := db.
+		Where("delivered_at IS NULL AND next_attempt_at <= ?", now).
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// MarkDelivered marks the message as delivered so it is never sent again
+func (m *OutboxMessage) MarkDelivered(db *gorm.DB, now time.Time) error {
+	m.DeliveredAt = &now
+	return db.Model(m).Update("delivered_at", now).Error
+}
+
+// MarkFailed records the failed attempt and when to try again
+func (m *OutboxMessage) MarkFailed(db *gorm.DB, sendErr error, next time.Time) error {
+	m.Attempts++
+	m.LastError = sendErr.Error()
+	m.NextAttemptAt = next
+	return db.Model(m).Updates(map[string]interface{}{
+		"attempts":        m.Attempts,
+		"last_error":      m.LastError,
+		"next_attempt_at": m.NextAttemptAt,
+	}).Error
+}
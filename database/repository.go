@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+)
+
+// ErrNotFound is returned by repositories when there is no row with the given id
+var ErrNotFound = errors.New("not found")
+
+// UserRepository stores the users of the bot
+type UserRepository interface {
+	// FirstOrCreate loads the user with the id of user and their settings into user, or creates it when it does not exist
+	FirstOrCreate(ctx context.Context, user *User) error
+	// SaveSession saves the fitforfree session of the user, nil removes it
+	SaveSession(ctx context.Context, user User) error
+	// SaveVenues saves the extra venues of the user and their home venue setting
+	SaveVenues(ctx context.Context, user User) error
+}
+
+// NotiRepository stores the notis of users
+type NotiRepository interface {
+	// Create creates a noti for the user and stores the lesson, an existing noti gets the given options
+	Create(ctx context.Context, user User, lesson fitforfree.Lesson, options NotiOptions) error
+	// Get returns the noti with the id, ErrNotFound if there is none
+	Get(ctx context.Context, id uint) (Noti, error)
+	// All returns all notis with their user, the user's settings and lesson
+	All(ctx context.Context) ([]Noti, error)
+	// ByUser returns the notis of the user with their lesson
+	ByUser(ctx context.Context, userID uint) ([]Noti, error)
+	// SaveState saves whether the noti books automatically and its watch state
+	SaveState(ctx context.Context, noti Noti) error
+	// Delete deletes the noti with the id
+	Delete(ctx context.Context, id uint) error
+	// DeleteByUser deletes all notis of the user
+	DeleteByUser(ctx context.Context, userID uint) error
+	// DeleteByLesson deletes the noti of the user for the lesson
+	DeleteByLesson(ctx context.Context, userID uint, lessonID string) error
+}
+
+// LessonRepository stores the lessons notis are for
+type LessonRepository interface {
+	// Get returns the lesson with the id, ErrNotFound if there is none
+	Get(ctx context.Context, id string) (Lesson, error)
+	// Update saves the fields of the lesson that come from fitforfree
+	Update(ctx context.Context, lesson Lesson) error
+}
+
+// SettingsRepository stores the settings of users, they are loaded with the user
+type SettingsRepository interface {
+	// Save creates or updates the settings of the user
+	Save(ctx context.Context, settings UserSettings) error
+}
+
+// ObservationRepository stores the availability observed of lessons
+type ObservationRepository interface {
+	// Record stores the availability of the lessons as observed at observedAt, unchanged lessons are not stored again
+	Record(ctx context.Context, lessons []fitforfree.Lesson, observedAt time.Time) error
+	// ByActivity returns the observations of lessons of the activity starting after since, by lesson and time
+	ByActivity(ctx context.Context, activity string, since time.Time) ([]Observation, error)
+	// Purge deletes the observations of lessons that started before the given time and returns how many
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OutboxRepository stores the messages to users until telegram accepts them
+type OutboxRepository interface {
+	// Add queues the message to be sent
+	Add(ctx context.Context, message *OutboxMessage) error
+	// Pending returns at most limit undelivered messages that should be attempted at now, oldest first
+	Pending(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error)
+	// MarkDelivered marks the message as delivered so it is never sent again
+	MarkDelivered(ctx context.Context, message *OutboxMessage, now time.Time) error
+	// MarkFailed records the failed attempt of the message and when to try again
+	MarkFailed(ctx context.Context, message *OutboxMessage, sendErr error, next time.Time) error
+}
+
+// CleanupRepository removes the notis and lessons that are not needed anymore
+type CleanupRepository interface {
+	// StartedNotis returns the notis whose lesson started before now, with their lesson, user and the user's settings
+	StartedNotis(ctx context.Context, now time.Time) ([]Noti, error)
+	// Retire deletes the noti and queues the message to its user in one transaction
+	Retire(ctx context.Context, noti Noti, message *OutboxMessage) error
+	// DeleteStartedLessons soft deletes the lessons that started before now and have no notis anymore
+	DeleteStartedLessons(ctx context.Context, now time.Time) (int64, error)
+	// Purge hard deletes the notis and lessons that were soft deleted before the given time
+	Purge(ctx context.Context, before time.Time) (notis int64, lessons int64, err error)
+}
+
+// AlertRepository stores the alerts of notis
+type AlertRepository interface {
+	// Record stores the alert
+	Record(ctx context.Context, alert *Alert) error
+	// Since returns the alerts since the given time with their user, in the order they were alerted
+	Since(ctx context.Context, since time.Time) ([]Alert, error)
+}
+
+// Repositories are the repositories of one database, handlers use them instead of querying the database themselves
+type Repositories struct {
+	Users        UserRepository
+	Settings     SettingsRepository
+	Notis        NotiRepository
+	Lessons      LessonRepository
+	Observations ObservationRepository
+	Alerts       AlertRepository
+	Outbox       OutboxRepository
+	Cleanup      CleanupRepository
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestRepositories runs on SQLite, and on PostgreSQL when TEST_DATABASE_DSN is set to an empty database
+func TestRepositories(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testRepositories(t, openTemp(t))
+	})
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Log("TEST_DATABASE_DSN is not set, skipping PostgreSQL")
+		return
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		db := Open(Postgres, dsn, logger.Discard)
+		t.Cleanup(func() {
+			if _, err := NewMigrator(db, Migrations).Down(0); err != nil {
+				t.Error(err)
+			}
+		})
+		testRepositories(t, db)
+	})
+}
+
+func testRepositories(t *testing.T, db *gorm.DB) {
+	if _, err := NewMigrator(db, Migrations).Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	repositories := NewRepositories(db)
+
+	user := User{ID: 1, ChatID: 10}
+	if err := repositories.Users.FirstOrCreate(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	user.Session = []byte("session")
+	user.SetHomeVenue("venue")
+	if err := repositories.Users.SaveSession(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := repositories.Users.SaveVenues(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := User{ID: 1}
+	if err := repositories.Users.FirstOrCreate(ctx, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if string(loaded.Session) != "session" || loaded.Settings.HomeVenue != "venue" {
+		t.Errorf("Expected the session and venues to be saved, got %+v", loaded)
+	}
+
+	// Saving settings twice updates them
+	if err := repositories.Settings.Save(ctx, UserSettings{UserID: 1, Language: "en"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repositories.Settings.Save(ctx, UserSettings{UserID: 1, Language: "en", Timezone: "Europe/London"}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded = User{ID: 1}
+	if err := repositories.Users.FirstOrCreate(ctx, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Settings.Language != "en" || loaded.Settings.Timezone != "Europe/London" {
+		t.Errorf("Expected the settings to be loaded with the user, got %+v", loaded.Settings)
+	}
+
+	lesson := fitforfree.Lesson{
+		ID:             "lesson",
+		Activity:       fitforfree.Activity{Name: "Spinning"},
+		StartTimestamp: uint(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := repositories.Notis.Create(ctx, user, lesson, NotiOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// Creating it again updates the options
+	if err := repositories.Notis.Create(ctx, user, lesson, NotiOptions{AutoBook: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	notis, err := repositories.Notis.ByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notis) != 1 || !notis[0].AutoBook || notis[0].Lesson.Name != "Spinning" {
+		t.Fatalf("Expected one noti that books automatically with its lesson, got %+v", notis)
+	}
+
+	alertedAt := time.Now()
+	noti := notis[0]
+	noti.AlertedAt = &alertedAt
+	if err := repositories.Notis.SaveState(ctx, noti); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := repositories.Notis.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].AlertedAt == nil || all[0].User.ChatID != 10 || all[0].User.Settings.Language != "en" {
+		t.Errorf("Expected the noti with its state, user and settings, got %+v", all)
+	}
+
+	stored, err := repositories.Lessons.Get(ctx, "lesson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.Instructor = "Anna"
+	if err := repositories.Lessons.Update(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := repositories.Lessons.Get(ctx, "lesson"); stored.Instructor != "Anna" {
+		t.Errorf("Expected the instructor to be updated, got %q", stored.Instructor)
+	}
+
+	if err := repositories.Alerts.Record(ctx, &Alert{LessonID: "lesson", NotiID: noti.ID, UserID: user.ID, AlertedAt: alertedAt}); err != nil {
+		t.Fatal(err)
+	}
+	alerts, err := repositories.Alerts.Since(ctx, alertedAt.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].User.ChatID != 10 {
+		t.Errorf("Expected the alert with its user, got %+v", alerts)
+	}
+
+	if err := repositories.Notis.DeleteByLesson(ctx, user.ID, "lesson"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repositories.Notis.Get(ctx, noti.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted noti, got %v", err)
+	}
+	if _, err := repositories.Lessons.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing lesson, got %v", err)
+	}
+}
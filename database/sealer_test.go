@@ -0,0 +1,45 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealer(t *testing.T) {
+	if _, err := NewSealer([]byte("short")); err == nil {
+		t.Error("Should not accept a key that is not 32 bytes")
+	}
+
+	sealer, err := NewSealer([]byte("01234567890123456789012345678901"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := sealer.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("Sealed data contains the plaintext")
+	}
+
+	opened, err := sealer.Open(sealed)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(opened) != "secret" {
+		t.Errorf("Expected secret, got %s", opened)
+	}
+
+	other, _ := NewSealer([]byte("10987654321098765432109876543210"))
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("Should not open with another key")
+	}
+
+	sealed[len(sealed)-1]++
+	if _, err := sealer.Open(sealed); err == nil {
+		t.Error("Should not open tampered data")
+	}
+}
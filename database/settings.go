@@ -0,0 +1,93 @@
+package database
+
+import (
+	"time"
+)
+
+const (
+	// DefaultTimezone is the timezone of users that did not pick one, the timezone of the venues
+	DefaultTimezone = "Europe/Amsterdam"
+
+	// ClassTypeGroup and ClassTypeFree are the default class types a user can pick, empty asks every time
+	ClassTypeGroup = "group"
+	ClassTypeFree  = "free"
+
+	// StyleFull sends the whole message with sound, StyleShort a single line and StyleSilent the whole message without sound
+	StyleFull   = "full"
+	StyleShort  = "short"
+	StyleSilent = "silent"
+)
+
+// UserSettings model, the preferences of a user
+// Users without settings, and settings left empty, get the defaults
+type UserSettings struct {
+	UserID uint `gorm:"primaryKey;autoIncrement:false"`
+	// Language of the messages to the user, nl or en, empty for dutch
+	Language string
+	// Timezone is the name of the timezone times are shown and entered in, empty for DefaultTimezone
+	Timezone string
+	// QuietFrom and QuietUntil are the hours of the day in the user's timezone in which messages are silent, none when equal
+	QuietFrom  uint8
+	QuietUntil uint8
+	// ClassType is ClassTypeGroup or ClassTypeFree to skip asking it when adding a noti, empty to ask
+	ClassType string
+	// NotificationStyle is StyleFull, StyleShort or StyleSilent, empty for StyleFull
+	NotificationStyle string
+	// HomeVenue is the id of the venue the user picked with /venues, empty for the VENUE environment variable
+	HomeVenue string
+}
+
+// Location returns the timezone of the user, DefaultTimezone when it is not set or does not exist
+func (s UserSettings) Location() *time.Location {
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Style returns the notification style of the user, StyleFull when it is not set
+func (s UserSettings) Style() string {
+	if s.NotificationStyle == "" {
+		return StyleFull
+	}
+	return s.NotificationStyle
+}
+
+// HasQuietHours returns if the user set quiet hours
+func (s UserSettings) HasQuietHours() bool {
+	return s.QuietFrom != s.QuietUntil
+}
+
+// Quiet returns if t is in the quiet hours of the user, quiet hours can go past midnight like 22 to 7
+func (s UserSettings) Quiet(t time.Time) bool {
+	if !s.HasQuietHours() {
+		return false
+	}
+
+	hour := uint8(t.In(s.Location()).Hour())
+	if s.QuietFrom < s.QuietUntil {
+		return hour >= s.QuietFrom && hour < s.QuietUntil
+	}
+	return hour >= s.QuietFrom || hour < s.QuietUntil
+}
+
+// QuietEnd returns when the quiet hours t is in end, t itself when it is not in the quiet hours
+func (s UserSettings) QuietEnd(t time.Time) time.Time {
+	if !s.Quiet(t) {
+		return t
+	}
+
+	local := t.In(s.Location())
+	end := time.Date(local.Year(), local.Month(), local.Day(), int(s.QuietUntil), 0, 0, 0, local.Location())
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
@@ -0,0 +1,265 @@
+package fitforfree
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LessonGetter gets lessons between 2 timestamps, Session implements it
+type LessonGetter interface {
+	GetLessons(ctx context.Context, start uint, end uint, venues []string) ([]Lesson, error)
+}
+
+// CacheStats are the amount of venue lookups served from the cache and from the api
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses", s.Hits, s.Misses)
+}
+
+// LessonCache caches lessons per venue and time window in front of a LessonGetter
+// Schedule data (which lessons there are) is kept longer than availability data (how many spots are left)
+type LessonCache struct {
+	getter          LessonGetter
+	scheduleTTL     time.Duration
+	availabilityTTL time.Duration
+	// now is replaced in tests
+	now func() time.Time
+
+	// OnFetch is called with the lessons of every upstream fetch, it should be set before the cache is used
+	OnFetch func(lessons []Lesson, fetchedAt time.Time)
+
+	lock   sync.Mutex
+	venues map[string]*venueCache
+
+	hits   uint64
+	misses uint64
+}
+
+// venueCache holds the lessons of one venue and the windows they were fetched for
+type venueCache struct {
+	// lock is held while fetching so concurrent requests for the venue wait for one fetch
+	lock    sync.Mutex
+	windows []cacheWindow
+	lessons map[string]Lesson
+}
+
+// cacheWindow is a fetched timeframe, start and end are inclusive
+type cacheWindow struct {
+	start     uint
+	end       uint
+	fetchedAt time.Time
+}
+
+// NewLessonCache returns a cache in front of getter, availabilityTTL should be shorter than scheduleTTL
+func NewLessonCache(getter LessonGetter, scheduleTTL time.Duration, availabilityTTL time.Duration) *LessonCache {
+	return &LessonCache{
+		getter:          getter,
+		scheduleTTL:     scheduleTTL,
+		availabilityTTL: availabilityTTL,
+		now:             time.Now,
+		venues:          make(map[string]*venueCache),
+	}
+}
+
+// Schedule gets lessons starting between 2 timestamps, allowing data up to the schedule ttl old
+// Use it when the amount of spots available does not have to be recent
+func (c *LessonCache) Schedule(ctx context.Context, start uint, end uint, venues []string) ([]Lesson, error) {
+	return c.get(ctx, start, end, venues, c.scheduleTTL)
+}
+
+// Availability gets lessons starting between 2 timestamps, allowing data up to the availability ttl old
+func (c *LessonCache) Availability(ctx context.Context, start uint, end uint, venues []string) ([]Lesson, error) {
+	return c.get(ctx, start, end, venues, c.availabilityTTL)
+}
+
+// Stats returns the hits and misses of the cache so far
+func (c *LessonCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+func (c *LessonCache) get(ctx context.Context, start uint, end uint, venues []string, ttl time.Duration) ([]Lesson, error) {
+	if start > end {
+		return nil, fmt.Errorf("fitforfree: start %d is after end %d", start, end)
+	}
+
+	lessons := make([]Lesson, 0)
+	for _, venue := range venues {
+		venueLessons, err := c.getVenue(ctx, start, end, venue, ttl)
+		if err != nil {
+			return nil, err
+		}
+		lessons = append(lessons, venueLessons...)
+	}
+
+	sort.Slice(lessons, func(i, j int) bool {
+		return lessons[i].StartTimestamp < lessons[j].StartTimestamp
+	})
+
+	return lessons, nil
+}
+
+func (c *LessonCache) getVenue(ctx context.Context, start uint, end uint, venue string, ttl time.Duration) ([]Lesson, error) {
+	c.lock.Lock()
+	vc, ok := c.venues[venue]
+	if !ok {
+		vc = &venueCache{lessons: make(map[string]Lesson)}
+		c.venues[venue] = vc
+	}
+	c.lock.Unlock()
+
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+
+	now := c.now()
+	vc.prune(now.Add(-c.maxTTL()))
+
+	if fetchStart, fetchEnd, missing := vc.missing(start, end, now.Add(-ttl)); missing {
+		atomic.AddUint64(&c.misses, 1)
+
+		fetched, err := c.getter.GetLessons(ctx, fetchStart, fetchEnd, []string{venue})
+		if err != nil {
+			return nil, err
+		}
+
+		vc.store(fetchStart, fetchEnd, fetched, now)
+
+		if c.OnFetch != nil {
+			c.OnFetch(fetched, now)
+		}
+	} else {
+		atomic.AddUint64(&c.hits, 1)
+	}
+
+	lessons := make([]Lesson, 0)
+	for _, lesson := range vc.lessons {
+		if lesson.StartTimestamp >= start && lesson.StartTimestamp <= end {
+			lessons = append(lessons, lesson)
+		}
+	}
+	return lessons, nil
+}
+
+func (c *LessonCache) maxTTL() time.Duration {
+	if c.scheduleTTL > c.availabilityTTL {
+		return c.scheduleTTL
+	}
+	return c.availabilityTTL
+}
+
+// missing returns the window that needs to be fetched to cover start to end with data fetched after freshSince
+// Multiple gaps are merged into one window so they cost a single request
+func (v *venueCache) missing(start uint, end uint, freshSince time.Time) (uint, uint, bool) {
+	fresh := make([]cacheWindow, 0, len(v.windows))
+	for _, w := range v.windows {
+		if !w.fetchedAt.Before(freshSince) {
+			fresh = append(fresh, w)
+		}
+	}
+
+	sort.Slice(fresh, func(i, j int) bool {
+		return fresh[i].start < fresh[j].start
+	})
+
+	var gapStart, gapEnd uint
+	missing := false
+	cursor := start
+	for _, w := range fresh {
+		if cursor > end {
+			break
+		}
+
+		if w.end < cursor {
+			continue
+		}
+
+		if w.start > cursor {
+			// Gap between the cursor and this window
+			if !missing {
+				gapStart = cursor
+				missing = true
+			}
+
+			if w.start > end {
+				break
+			}
+			gapEnd = w.start - 1
+		}
+
+		if w.end == ^uint(0) {
+			cursor = w.end
+			break
+		}
+		cursor = w.end + 1
+	}
+
+	if cursor <= end {
+		if !missing {
+			gapStart = cursor
+			missing = true
+		}
+		gapEnd = end
+	}
+
+	return gapStart, gapEnd, missing
+}
+
+// store replaces the lessons from start to end with the fetched lessons
+func (v *venueCache) store(start uint, end uint, lessons []Lesson, fetchedAt time.Time) {
+	for id, lesson := range v.lessons {
+		if lesson.StartTimestamp >= start && lesson.StartTimestamp <= end {
+			delete(v.lessons, id)
+		}
+	}
+
+	for _, lesson := range lessons {
+		// Lessons outside the window would not be refreshed with it
+		if lesson.StartTimestamp >= start && lesson.StartTimestamp <= end {
+			v.lessons[lesson.ID] = lesson
+		}
+	}
+
+	// Windows covered by the new window are outdated
+	windows := v.windows[:0]
+	for _, w := range v.windows {
+		if w.start < start || w.end > end {
+			windows = append(windows, w)
+		}
+	}
+	v.windows = append(windows, cacheWindow{start: start, end: end, fetchedAt: fetchedAt})
+}
+
+// prune removes windows fetched before expiredBefore and lessons that are not in any window anymore
+func (v *venueCache) prune(expiredBefore time.Time) {
+	windows := v.windows[:0]
+	for _, w := range v.windows {
+		if !w.fetchedAt.Before(expiredBefore) {
+			windows = append(windows, w)
+		}
+	}
+	v.windows = windows
+
+	for id, lesson := range v.lessons {
+		covered := false
+		for _, w := range v.windows {
+			if lesson.StartTimestamp >= w.start && lesson.StartTimestamp <= w.end {
+				covered = true
+				break
+			}
+		}
+
+		if !covered {
+			delete(v.lessons, id)
+		}
+	}
+}
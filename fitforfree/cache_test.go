@@ -0,0 +1,123 @@
+package fitforfree
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fetch struct {
+	start uint
+	end   uint
+}
+
+type mockGetter struct {
+	lock    sync.Mutex
+	fetches []fetch
+	lessons []Lesson
+}
+
+func (m *mockGetter) GetLessons(ctx context.Context, start uint, end uint, venues []string) ([]Lesson, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.fetches = append(m.fetches, fetch{start: start, end: end})
+	return m.lessons, nil
+}
+
+func TestLessonCacheSharesRequests(t *testing.T) {
+	getter := &mockGetter{lessons: []Lesson{{ID: "1", StartTimestamp: 150}, {ID: "2", StartTimestamp: 250}}}
+	cache := NewLessonCache(getter, time.Hour, time.Minute)
+	observed := 0
+	cache.OnFetch = func(lessons []Lesson, fetchedAt time.Time) {
+		observed += len(lessons)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lessons, err := cache.Schedule(context.Background(), 100, 200, []string{"venue"})
+			if err != nil {
+				t.Error(err)
+			}
+
+			if len(lessons) != 1 || lessons[0].ID != "1" {
+				t.Errorf("Expected lesson 1, got %+v", lessons)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(getter.fetches) != 1 {
+		t.Errorf("Expected 1 fetch, got %d", len(getter.fetches))
+	}
+
+	if observed != 2 {
+		t.Errorf("Expected the 2 fetched lessons to be observed, got %d", observed)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 4 || stats.Misses != 1 {
+		t.Errorf("Expected 4 hits and 1 miss, got %s", stats)
+	}
+}
+
+func TestLessonCacheFetchesGaps(t *testing.T) {
+	getter := &mockGetter{}
+	cache := NewLessonCache(getter, time.Hour, time.Minute)
+
+	cache.Schedule(context.Background(), 100, 200, []string{"venue"})
+	cache.Schedule(context.Background(), 300, 400, []string{"venue"})
+
+	// Only the part that is not cached yet is fetched, the gaps are merged into one window
+	cache.Schedule(context.Background(), 150, 350, []string{"venue"})
+	cache.Schedule(context.Background(), 50, 450, []string{"venue"})
+
+	expected := []fetch{{100, 200}, {300, 400}, {201, 299}, {50, 450}}
+	if len(getter.fetches) != len(expected) {
+		t.Fatalf("Expected %d fetches, got %+v", len(expected), getter.fetches)
+	}
+
+	for i, f := range expected {
+		if getter.fetches[i] != f {
+			t.Errorf("Expected fetch %+v, got %+v", f, getter.fetches[i])
+		}
+	}
+}
+
+func TestLessonCacheTTLs(t *testing.T) {
+	getter := &mockGetter{lessons: []Lesson{{ID: "1", StartTimestamp: 150, SpotsAvailable: 0}}}
+	cache := NewLessonCache(getter, time.Hour, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time {
+		return now
+	}
+
+	cache.Availability(context.Background(), 100, 200, []string{"venue"})
+
+	now = now.Add(time.Minute * 2)
+	getter.lessons = []Lesson{{ID: "1", StartTimestamp: 150, SpotsAvailable: 1}}
+
+	// Schedule data may still be used
+	lessons, _ := cache.Schedule(context.Background(), 100, 200, []string{"venue"})
+	if len(getter.fetches) != 1 || lessons[0].SpotsAvailable != 0 {
+		t.Error("Schedule should be served from the cache")
+	}
+
+	// Availability data is outdated
+	lessons, _ = cache.Availability(context.Background(), 100, 200, []string{"venue"})
+	if len(getter.fetches) != 2 || lessons[0].SpotsAvailable != 1 {
+		t.Error("Availability should be fetched again")
+	}
+
+	// Lessons that are gone upstream are removed from the cache
+	now = now.Add(time.Hour * 2)
+	getter.lessons = []Lesson{}
+	lessons, _ = cache.Schedule(context.Background(), 100, 200, []string{"venue"})
+	if len(getter.fetches) != 3 || len(lessons) != 0 {
+		t.Error("Schedule should be fetched again after the schedule ttl")
+	}
+}
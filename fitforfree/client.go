@@ -0,0 +1,273 @@
+package fitforfree
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultBaseURL is the url of the api used by the FitForFree app
+const DefaultBaseURL = "https://electrolyte.fitforfree.nl/"
+
+// DefaultAppVersion is sent as the app-version header, the api rejects unknown versions
+const DefaultAppVersion = "4.6.4"
+
+// DefaultTimeout is the timeout of a single request when none is configured
+const DefaultTimeout = time.Second * 15
+
+// DefaultMaxRetries is how many times a failed request is retried when not configured
+const DefaultMaxRetries = 3
+
+// DefaultRetryBaseDelay is the delay before the first retry when not configured, it doubles every retry
+const DefaultRetryBaseDelay = time.Millisecond * 500
+
+// DefaultRetryMaxDelay is the maximum delay between retries when not configured
+const DefaultRetryMaxDelay = time.Second * 30
+
+// Config configures a Client, zero values are replaced by the defaults
+type Config struct {
+	BaseURL    string
+	AppVersion string
+	// Timeout of a single request, overrides the timeout of HTTPClient when set
+	Timeout    time.Duration
+	HTTPClient *http.Client
+	// RateLimiter every request waits on, nil means requests are not limited
+	RateLimiter *RateLimiter
+	// MaxRetries of a failed request, set to -1 to never retry
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// RecordDir records every request and response as a fixture in the directory, see RecordingTransport
+	RecordDir string
+	// ReplayDir serves the fixtures in the directory instead of calling the api, see ReplayTransport
+	ReplayDir string
+}
+
+// Client executes requests against the FitForFree api
+type Client struct {
+	baseURL        string
+	appVersion     string
+	httpClient     *http.Client
+	limiter        *RateLimiter
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+}
+
+// NewClient returns a client configured with config
+func NewClient(config Config) *Client {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+
+	if config.AppVersion == "" {
+		config.AppVersion = DefaultAppVersion
+	}
+
+	httpClient := &http.Client{Timeout: DefaultTimeout}
+	if config.HTTPClient != nil {
+		// Copy so we don't change the timeout of a client that is shared
+		c := *config.HTTPClient
+		httpClient = &c
+	}
+
+	if config.Timeout > 0 {
+		httpClient.Timeout = config.Timeout
+	}
+
+	if config.ReplayDir != "" {
+		httpClient.Transport = &ReplayTransport{Dir: config.ReplayDir}
+	} else if config.RecordDir != "" {
+		httpClient.Transport = &RecordingTransport{Dir: config.RecordDir, Transport: httpClient.Transport}
+	}
+
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	} else if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+
+	if config.RetryBaseDelay == 0 {
+		config.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+
+	if config.RetryMaxDelay == 0 {
+		config.RetryMaxDelay = DefaultRetryMaxDelay
+	}
+
+	return &Client{
+		baseURL:        config.BaseURL,
+		appVersion:     config.AppVersion,
+		httpClient:     httpClient,
+		limiter:        config.RateLimiter,
+		maxRetries:     config.MaxRetries,
+		retryBaseDelay: config.RetryBaseDelay,
+		retryMaxDelay:  config.RetryMaxDelay,
+	}
+}
+
+// do executes a request to path and returns the response body, data is sent as json when it is not nil
+// Failed requests are retried with exponential backoff when it is safe to do so
+func (c *Client) do(ctx context.Context, method string, path string, data interface{}, bearerToken string) ([]byte, error) {
+	var body []byte
+	if data != nil {
+		j, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("fitforfree: can't format %+v as json: %w", data, err)
+		}
+		body = j
+	}
+
+	for attempt := 0; ; attempt++ {
+		resBytes, err := c.doOnce(ctx, method, path, body, bearerToken)
+		if err == nil || attempt >= c.maxRetries || !shouldRetry(method, err) || ctx.Err() != nil {
+			return resBytes, err
+		}
+
+		delay := c.backoff(attempt, err)
+		log.Printf("WARNING: Retrying %s %s in %s after error: %+v", method, path, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// doOnce executes a single request after waiting for the rate limiter
+func (c *Client) doOnce(ctx context.Context, method string, path string, body []byte, bearerToken string) ([]byte, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, &NetworkError{Err: err}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("fitforfree: can't create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("app-version", c.appVersion)
+
+	if len(bearerToken) > 0 {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+
+	defer res.Body.Close()
+
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		statusErr := newStatusError(res.StatusCode, resBytes)
+		statusErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		return nil, statusErr
+	}
+
+	return resBytes, nil
+}
+
+// shouldRetry returns if a request with method that failed with err can safely be retried
+func shouldRetry(method string, err error) bool {
+	statusErr := new(StatusError)
+	if errors.As(err, &statusErr) {
+		// Too many requests means the request was not handled, so it is always safe to retry
+		if statusErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+
+		return isIdempotent(method) && errors.Is(err, ErrUpstream)
+	}
+
+	networkErr := new(NetworkError)
+	return isIdempotent(method) && errors.As(err, &networkErr)
+}
+
+// isIdempotent returns if executing a request with method multiple times has the same effect as executing it once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before retrying, following the Retry-After the api sent if any
+// Otherwise it is a random delay up to the base delay doubled every attempt (full jitter)
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	statusErr := new(StatusError)
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		if statusErr.RetryAfter > c.retryMaxDelay {
+			return c.retryMaxDelay
+		}
+		return statusErr.RetryAfter
+	}
+
+	max := c.retryBaseDelay << uint(attempt)
+	if max > c.retryMaxDelay || max <= 0 {
+		max = c.retryMaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(max))) + 1
+}
+
+// parseRetryAfter parses a Retry-After header which is either an amount of seconds or a date, 0 if there is none
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// newStatusError creates a StatusError, using the status message from the body if the api sent one
+func newStatusError(statusCode int, body []byte) *StatusError {
+	statusErr := &StatusError{StatusCode: statusCode}
+
+	res := struct{ Status Status }{}
+	if err := json.Unmarshal(body, &res); err == nil {
+		statusErr.Message = res.Status.Message
+	}
+
+	return statusErr
+}
+
+// decode unmarshals body into v, wrapping any error in a DecodeError
+func decode(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return &DecodeError{Body: body, Err: err}
+	}
+	return nil
+}
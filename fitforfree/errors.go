@@ -0,0 +1,70 @@
+package fitforfree
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnauthorized is matched by errors returned when the api rejects the credentials or bearer token
+var ErrUnauthorized = errors.New("fitforfree: unauthorized")
+
+// ErrUpstream is matched by errors returned when the api responds with a 5xx status code
+var ErrUpstream = errors.New("fitforfree: upstream error")
+
+// ErrVenueNotFound is returned when no venue matches the requested name
+var ErrVenueNotFound = errors.New("fitforfree: venue not found")
+
+// StatusError is returned when the api responds with a status code other than 200
+type StatusError struct {
+	StatusCode int
+	// Message is the status message the api sent along, if any
+	Message string
+	// RetryAfter is how long the api asked us to wait before retrying, 0 if it didn't
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("fitforfree: unexpected status code %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("fitforfree: unexpected status code %d", e.StatusCode)
+}
+
+// Is makes errors.Is match ErrUnauthorized for 401 and ErrUpstream for 5xx responses
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == 401
+	case ErrUpstream:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// DecodeError is returned when the response body can not be decoded
+type DecodeError struct {
+	Body []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("fitforfree: can't decode response %q: %v", e.Body, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NetworkError is returned when the request could not be executed
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("fitforfree: request failed: %v", e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
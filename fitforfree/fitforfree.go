@@ -1,16 +1,11 @@
 package fitforfree
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
-	"net/http"
 	"net/url"
 )
 
-// TODO: Make all exported functions return errors
-
 type LessonResponse struct {
 	Status Status
 	Data   LessonResponseData
@@ -44,6 +39,19 @@ type Lesson struct {
 	RoomName               string
 }
 
+// StatusCancelled is the status of a lesson that was cancelled
+const StatusCancelled = "cancelled"
+
+// IsCancelled returns if the lesson status means the lesson was cancelled
+func IsCancelled(status string) bool {
+	return status == StatusCancelled || status == "canceled"
+}
+
+// Cancelled returns if the lesson was cancelled
+func (l Lesson) Cancelled() bool {
+	return IsCancelled(l.Status)
+}
+
 func Filter(vs []Lesson, f func(Lesson) bool) []Lesson {
 	vsf := make([]Lesson, 0)
 	for _, v := range vs {
@@ -106,149 +114,79 @@ type User struct {
 	WeeklyTrainingGoal   uint        `json:"weekly_training_goal"`
 }
 
-const BASE_URL = "https://electrolyte.fitforfree.nl/"
-
-func doGetRequest(url string, bearerToken string) ([]byte, uint) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s", BASE_URL, url), nil)
-	if err != nil {
-		log.Panicf("ERROR: Error while creating request, err: %+v", err)
-	}
-
-	req.Header.Add("app-version", "4.6.4")
-
-	if len(bearerToken) > 0 {
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
-	}
-
-	res, err := client.Do(req)
+// Login logs in with the member id and postal code, the returned user's SessionID is the bearer token
+func (c *Client) Login(ctx context.Context, memberID string, postalCode string) (*User, error) {
+	bytesRes, err := c.do(ctx, "POST", "v0/login", map[string]interface{}{"memberid": memberID, "postcode": postalCode, "terms_accepted": true}, "")
 	if err != nil {
-		log.Printf("ERROR: Error while executing request, err: %+v", err)
-		return []byte{}, 400
+		return nil, err
 	}
 
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return []byte{}, uint(res.StatusCode)
+	res := new(LoginResponse)
+	if err := decode(bytesRes, res); err != nil {
+		return nil, err
 	}
 
-	// Turn response into bytes
-	buffer := new(bytes.Buffer)
-	buffer.ReadFrom(res.Body)
-	bytes := buffer.Bytes()
-
-	return bytes, 200
+	return &res.Data, nil
 }
 
-func doPostRequest(url string, data interface{}, bearerToken string) ([]byte, uint) {
-	client := &http.Client{}
-
-	json, err := json.Marshal(data)
+// GetAllVenues gets all venues of FitForFree
+func (c *Client) GetAllVenues(ctx context.Context, bearerToken string) ([]Venue, error) {
+	resBytes, err := c.do(ctx, "GET", "v1/venues", nil, bearerToken)
 	if err != nil {
-		log.Panicf("ERROR: Can not format data: %+v as json", data)
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", BASE_URL, url), bytes.NewBuffer(json))
-	if err != nil {
-		log.Panicf("ERROR: Error while creating request, err: %+v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("app-version", "4.6.4")
-
-	if len(bearerToken) > 0 {
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
-	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		log.Printf("ERROR: Error while executing request, err: %+v", err)
-		return []byte{}, 400
-	}
-
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return []byte{}, uint(res.StatusCode)
-	}
-
-	// Turn response into bytes
-	buffer := new(bytes.Buffer)
-	buffer.ReadFrom(res.Body)
-	bytes := buffer.Bytes()
-
-	return bytes, 200
-}
-
-func Login(memberID string, postalCode string) (*User, bool) {
-	bytesRes, statusCode := doPostRequest("v0/login", map[string]interface{}{"memberid": memberID, "postcode": postalCode, "terms_accepted": true}, "")
-	if statusCode != 200 {
-		if statusCode == 401 {
-			return new(User), false
-		}
-		log.Printf("ERROR: Status code not 200 but %d", statusCode)
-		return new(User), false
-	}
-
-	res := new(LoginResponse)
-	if err := json.Unmarshal(bytesRes, &res); err != nil {
-		log.Printf("ERROR: Can't parse json response into user: %s, err: %+v", string(bytesRes), err)
-		return new(User), false
+	venues := make([]Venue, 0)
+	if err := decode(resBytes, &venues); err != nil {
+		return nil, err
 	}
-
-	return &res.Data, true
+	return venues, nil
 }
 
-func GetAllVenues(bearerToken string) []Venue {
-	resBytes, statusCode := doGetRequest("v1/venues", bearerToken)
-	if statusCode != 200 {
-		log.Printf("ERROR: Status code GetAllVenues is %d instead of 200", statusCode)
-		return make([]Venue, 0)
-	}
-
-	venues := make([]Venue, 0)
-	if err := json.Unmarshal(resBytes, &venues); err != nil {
-		log.Printf("ERROR: Unable to unmarshal venues JSON: %s", string(resBytes))
+// GetVenueByName gets the venue with the given name, returns ErrVenueNotFound if there is none
+func (c *Client) GetVenueByName(ctx context.Context, name string, bearerToken string) (*Venue, error) {
+	venues, err := c.GetAllVenues(ctx, bearerToken)
+	if err != nil {
+		return nil, err
 	}
-	return venues
-}
 
-func GetVenueByName(name string, bearerToken string) (*Venue, bool) {
-	venues := GetAllVenues(bearerToken)
 	for _, venue := range venues {
 		if venue.Name == name {
-			return &venue, true
+			return &venue, nil
 		}
 	}
-	return new(Venue), false
+	return nil, ErrVenueNotFound
 }
 
 // GetLessons gets available free fitness lessons between 2 timestamps
-func GetLessons(start uint, end uint, venues []string, bearerToken string) []Lesson {
-	lessons := make([]Lesson, 0)
-
+func (c *Client) GetLessons(ctx context.Context, start uint, end uint, venues []string, bearerToken string) ([]Lesson, error) {
 	if start > end {
-		log.Panic("ERROR: Start time is after end time in GetLessons")
-		return lessons
+		return nil, fmt.Errorf("fitforfree: start %d is after end %d", start, end)
 	}
 
 	venuesQuery := url.QueryEscape(fmt.Sprintf("%q", venues))
 
-	bytesRes, statusCode := doGetRequest(fmt.Sprintf("v0/lessons/?venues=%s&from=%d&to=%d&language=%s", venuesQuery, start, end, "nl_NL"), bearerToken)
-
-	if statusCode != 200 {
-		log.Printf("ERROR: Status code while getting lessons is: %d when 200 is expected", statusCode)
-		return lessons
+	bytesRes, err := c.do(ctx, "GET", fmt.Sprintf("v0/lessons/?venues=%s&from=%d&to=%d&language=%s", venuesQuery, start, end, "nl_NL"), nil, bearerToken)
+	if err != nil {
+		return nil, err
 	}
 
-	// Turn bytes into map string to interface
 	resJSON := new(LessonResponse)
-	if err := json.Unmarshal(bytesRes, &resJSON); err != nil {
-		log.Printf("ERROR: Json could not be parsed while getting lessons, err: %+v", err)
-		return lessons
+	if err := decode(bytesRes, resJSON); err != nil {
+		return nil, err
 	}
 
-	return resJSON.Data.Lessons
+	return resJSON.Data.Lessons, nil
+}
+
+// BookLesson books the lesson for the user the bearer token belongs to
+func (c *Client) BookLesson(ctx context.Context, lessonID string, bearerToken string) error {
+	_, err := c.do(ctx, "POST", fmt.Sprintf("v0/lessons/%s/booking", url.PathEscape(lessonID)), nil, bearerToken)
+	return err
+}
+
+// CancelBooking cancels the booking of the lesson for the user the bearer token belongs to
+func (c *Client) CancelBooking(ctx context.Context, lessonID string, bearerToken string) error {
+	_, err := c.do(ctx, "DELETE", fmt.Sprintf("v0/lessons/%s/booking", url.PathEscape(lessonID)), nil, bearerToken)
+	return err
 }
@@ -0,0 +1,288 @@
+package fitforfree
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	return NewClient(Config{BaseURL: server.URL + "/", RetryBaseDelay: time.Millisecond}), server
+}
+
+func TestGetLessons(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/lessons/" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+
+		if r.Header.Get("app-version") != DefaultAppVersion {
+			t.Error("app-version header not set")
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Error("Authorization header not set")
+		}
+
+		w.Write([]byte(`{"status":{"code":200},"data":{"lessons":[{"id":"1","spotsavailable":2}]}}`))
+	})
+	defer server.Close()
+
+	lessons, err := client.GetLessons(context.Background(), 1, 2, []string{"venue"}, "token")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(lessons) != 1 || lessons[0].ID != "1" || lessons[0].SpotsAvailable != 2 {
+		t.Errorf("Lessons not decoded correctly: %+v", lessons)
+	}
+
+	if _, err := client.GetLessons(context.Background(), 2, 1, []string{"venue"}, "token"); err == nil {
+		t.Error("Start after end should error")
+	}
+}
+
+type testErrorsPayload struct {
+	statusCode int
+	body       string
+	check      func(error) bool
+}
+
+func TestErrors(t *testing.T) {
+	payloads := []testErrorsPayload{
+		{
+			statusCode: 401,
+			body:       `{"status":{"code":401,"message":"Unauthorized"}}`,
+			check: func(err error) bool {
+				statusErr := new(StatusError)
+				return errors.Is(err, ErrUnauthorized) && errors.As(err, &statusErr) && statusErr.Message == "Unauthorized"
+			},
+		},
+		{
+			statusCode: 503,
+			check: func(err error) bool {
+				return errors.Is(err, ErrUpstream) && !errors.Is(err, ErrUnauthorized)
+			},
+		},
+		{
+			statusCode: 200,
+			body:       `{"status":`,
+			check: func(err error) bool {
+				decodeErr := new(DecodeError)
+				return errors.As(err, &decodeErr)
+			},
+		},
+	}
+
+	for _, payload := range payloads {
+		client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(payload.statusCode)
+			w.Write([]byte(payload.body))
+		})
+
+		if _, err := client.GetLessons(context.Background(), 1, 2, []string{"venue"}, ""); !payload.check(err) {
+			t.Errorf("Unexpected error for status %d: %+v", payload.statusCode, err)
+		}
+
+		if _, err := client.Login(context.Background(), "1", "1234AB"); !payload.check(err) {
+			t.Errorf("Unexpected login error for status %d: %+v", payload.statusCode, err)
+		}
+
+		server.Close()
+	}
+
+	// Server is closed so this is a network error
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {})
+	server.Close()
+
+	networkErr := new(NetworkError)
+	if _, err := client.GetAllVenues(context.Background(), ""); !errors.As(err, &networkErr) {
+		t.Errorf("Expected network error, got %+v", err)
+	}
+}
+
+func TestGetVenueByName(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"1","name":"Amsterdam"},{"id":"2","name":"Utrecht"}]`))
+	})
+	defer server.Close()
+
+	venue, err := client.GetVenueByName(context.Background(), "Utrecht", "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if venue.ID != "2" {
+		t.Error("Got the wrong venue")
+	}
+
+	if _, err := client.GetVenueByName(context.Background(), "Rotterdam", ""); !errors.Is(err, ErrVenueNotFound) {
+		t.Errorf("Expected ErrVenueNotFound, got %+v", err)
+	}
+}
+
+func TestBookLesson(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v0/lessons/1/booking" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer full" {
+			return
+		}
+
+		w.WriteHeader(409)
+		w.Write([]byte(`{"status":{"code":409,"message":"Les is vol"}}`))
+	})
+	defer server.Close()
+
+	if err := client.BookLesson(context.Background(), "1", "token"); err != nil {
+		t.Error(err)
+	}
+
+	statusErr := new(StatusError)
+	if err := client.BookLesson(context.Background(), "1", "full"); !errors.As(err, &statusErr) || statusErr.Message != "Les is vol" {
+		t.Errorf("Expected status error with message, got %+v", err)
+	}
+}
+
+func TestCancelBooking(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/v0/lessons/1/booking" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	defer server.Close()
+
+	if err := client.CancelBooking(context.Background(), "1", "token"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	requests := 0
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`[]`))
+	})
+	defer server.Close()
+
+	if _, err := client.GetAllVenues(context.Background(), ""); err != nil {
+		t.Error(err)
+	}
+
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+
+	// Posts are not idempotent so they are not retried on server errors
+	requests = 0
+	if err := client.BookLesson(context.Background(), "1", ""); !errors.Is(err, ErrUpstream) {
+		t.Errorf("Expected ErrUpstream, got %+v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+
+	// Retries stop after the maximum
+	requests = -10
+	if _, err := client.GetAllVenues(context.Background(), ""); !errors.Is(err, ErrUpstream) {
+		t.Errorf("Expected ErrUpstream, got %+v", err)
+	}
+
+	if requests != -10+DefaultMaxRetries+1 {
+		t.Errorf("Expected %d requests, got %d", DefaultMaxRetries+1, requests+10)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]time.Duration{
+		"":                              0,
+		"5":                             time.Second * 5,
+		"-1":                            0,
+		"Tue, 01 Dec 2020 12:00:30 GMT": time.Second * 30,
+		"Tue, 01 Dec 2020 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+
+	for header, expected := range cases {
+		if actual := parseRetryAfter(header, now); actual != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, header, actual)
+		}
+	}
+}
+
+func TestRetryAfterIsFollowed(t *testing.T) {
+	requests := 0
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(429)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	defer server.Close()
+
+	start := time.Now()
+	if err := client.BookLesson(context.Background(), "1", ""); err != nil {
+		t.Error(err)
+	}
+
+	if time.Since(start) < time.Second {
+		t.Error("Did not wait for Retry-After")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	for _, invalid := range []struct {
+		perSecond float64
+		burst     int
+	}{
+		{perSecond: 0, burst: 1},
+		{perSecond: -1, burst: 1},
+		{perSecond: math.NaN(), burst: 1},
+		{perSecond: math.Inf(1), burst: 1},
+		{perSecond: 1, burst: 0},
+	} {
+		if _, err := NewRateLimiter(invalid.perSecond, invalid.burst); err == nil {
+			t.Errorf("Expected an error for %v requests per second with bursts of %d", invalid.perSecond, invalid.burst)
+		}
+	}
+
+	limiter, err := NewRateLimiter(20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// 2 requests are allowed right away, the other 2 take 50ms each
+	if elapsed := time.Since(start); elapsed < time.Millisecond*90 {
+		t.Errorf("Rate limiter did not limit, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Error("Wait should stop when the context is done")
+	}
+}
@@ -0,0 +1,397 @@
+// Package fitforfreetest provides an in-process fake of the FitForFree api for tests
+package fitforfreetest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+)
+
+// venuesRegex gets the venues out of the venues query parameter which is formatted like ["a" "b"]
+var venuesRegex = regexp.MustCompile(`"([^"]*)"`)
+
+// lessonPathRegex matches the booking endpoints and captures the lesson id
+var lessonPathRegex = regexp.MustCompile(`^/v0/lessons/([^/]+)/booking$`)
+
+// Server is a fake FitForFree api serving v0/login, v1/venues, v0/lessons and the booking endpoints from memory
+type Server struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	venues   []fitforfree.Venue
+	lessons  []*lesson
+	members  map[string]*member
+	tokens   map[string]*member
+	requests map[string]int
+}
+
+type lesson struct {
+	fitforfree.Lesson
+	// statusCode is returned instead of the lessons when this lesson would be in the response
+	statusCode int
+	latency    time.Duration
+}
+
+type member struct {
+	postalCode string
+	user       fitforfree.User
+	booked     map[string]bool
+}
+
+// NewServer starts a fake api, close it when done
+func NewServer() *Server {
+	s := &Server{
+		members:  make(map[string]*member),
+		tokens:   make(map[string]*member),
+		requests: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v0/login", s.handleLogin)
+	mux.HandleFunc("/v1/venues", s.handleVenues)
+	mux.HandleFunc("/v0/lessons/", s.handleLessons)
+	s.Server = httptest.NewServer(s.count(mux))
+
+	return s
+}
+
+// Client returns a client for the fake api, with retries delayed by a millisecond so tests stay fast
+func (s *Server) Client(config fitforfree.Config) *fitforfree.Client {
+	config.BaseURL = s.URL + "/"
+	if config.RetryBaseDelay == 0 {
+		config.RetryBaseDelay = time.Millisecond
+	}
+	return fitforfree.NewClient(config)
+}
+
+// AddVenue adds a venue to the venues endpoint
+func (s *Server) AddVenue(venue fitforfree.Venue) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.venues = append(s.venues, venue)
+}
+
+// AddLesson adds a lesson, it is returned for requests with its VenueID or VenueName
+func (s *Server) AddLesson(l fitforfree.Lesson) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lessons = append(s.lessons, &lesson{Lesson: l})
+}
+
+// RemoveLesson removes the lesson so it is not returned anymore
+func (s *Server) RemoveLesson(lessonID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, l := range s.lessons {
+		if l.ID == lessonID {
+			s.lessons = append(s.lessons[:i], s.lessons[i+1:]...)
+			return
+		}
+	}
+}
+
+// UpdateLesson calls update with the lesson so tests can change it
+func (s *Server) UpdateLesson(lessonID string, update func(*fitforfree.Lesson)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if l := s.lesson(lessonID); l != nil {
+		update(&l.Lesson)
+	}
+}
+
+// SetSpots sets the amount of spots available for the lesson
+func (s *Server) SetSpots(lessonID string, spots uint8) {
+	s.UpdateLesson(lessonID, func(l *fitforfree.Lesson) {
+		l.SpotsAvailable = spots
+	})
+}
+
+// SetError makes requests that would return the lesson fail with statusCode, 0 removes the error
+func (s *Server) SetError(lessonID string, statusCode int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if l := s.lesson(lessonID); l != nil {
+		l.statusCode = statusCode
+	}
+}
+
+// SetLatency delays responses that return the lesson
+func (s *Server) SetLatency(lessonID string, latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if l := s.lesson(lessonID); l != nil {
+		l.latency = latency
+	}
+}
+
+// AddMember adds an account that can log in with the member id and postal code
+func (s *Server) AddMember(memberID string, postalCode string, user fitforfree.User) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	user.MemberID = memberID
+	s.members[memberID] = &member{postalCode: postalCode, user: user, booked: make(map[string]bool)}
+}
+
+// Token logs the member in and returns the bearer token, as if the member used the login endpoint
+func (s *Server) Token(memberID string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.login(s.members[memberID])
+}
+
+// ExpireTokens makes every token that was handed out invalid
+func (s *Server) ExpireTokens() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.tokens = make(map[string]*member)
+}
+
+// Booked returns if the member has booked the lesson
+func (s *Server) Booked(memberID string, lessonID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m, ok := s.members[memberID]
+	return ok && m.booked[lessonID]
+}
+
+// Requests returns how many requests were made to path, like /v0/lessons/
+func (s *Server) Requests(path string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.requests[path]
+}
+
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		if lessonPathRegex.MatchString(r.URL.Path) {
+			s.requests["/v0/lessons/booking"]++
+		} else {
+			s.requests[r.URL.Path]++
+		}
+		s.lock.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeStatus(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	body := struct {
+		MemberID string `json:"memberid"`
+		PostCode string `json:"postcode"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeStatus(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m, ok := s.members[body.MemberID]
+	if !ok || m.postalCode != body.PostCode {
+		writeStatus(w, http.StatusUnauthorized, "Lidnummer of postcode onjuist")
+		return
+	}
+
+	s.login(m)
+	writeJSON(w, fitforfree.LoginResponse{Status: fitforfree.Status{Code: 200}, Data: m.user})
+}
+
+func (s *Server) handleVenues(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+
+	s.lock.Lock()
+	venues := append([]fitforfree.Venue{}, s.venues...)
+	s.lock.Unlock()
+
+	writeJSON(w, venues)
+}
+
+func (s *Server) handleLessons(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if matches := lessonPathRegex.FindStringSubmatch(r.URL.Path); matches != nil {
+		if m == nil {
+			writeStatus(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		s.handleBooking(w, r, m, matches[1])
+		return
+	}
+
+	if r.URL.Path != "/v0/lessons/" || r.Method != http.MethodGet {
+		writeStatus(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	from, errFrom := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+	to, errTo := strconv.ParseUint(r.URL.Query().Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		writeStatus(w, http.StatusBadRequest, "Invalid from or to")
+		return
+	}
+
+	venues := make(map[string]bool)
+	for _, match := range venuesRegex.FindAllStringSubmatch(r.URL.Query().Get("venues"), -1) {
+		venues[match[1]] = true
+	}
+
+	s.lock.Lock()
+	lessons := make([]fitforfree.Lesson, 0)
+	var statusCode int
+	var latency time.Duration
+	for _, l := range s.lessons {
+		if uint64(l.StartTimestamp) < from || uint64(l.StartTimestamp) > to || !(venues[l.VenueID] || venues[l.VenueName]) {
+			continue
+		}
+
+		if l.statusCode != 0 {
+			statusCode = l.statusCode
+		}
+
+		if l.latency > latency {
+			latency = l.latency
+		}
+
+		lesson := l.Lesson
+		lesson.Booked = m != nil && m.booked[l.ID]
+		lessons = append(lessons, lesson)
+	}
+	s.lock.Unlock()
+
+	time.Sleep(latency)
+
+	if statusCode != 0 {
+		writeStatus(w, statusCode, "Fout bij het ophalen van de lessen")
+		return
+	}
+
+	writeJSON(w, fitforfree.LessonResponse{
+		Status: fitforfree.Status{Code: 200},
+		Data:   fitforfree.LessonResponseData{Lessons: lessons},
+	})
+}
+
+func (s *Server) handleBooking(w http.ResponseWriter, r *http.Request, m *member, lessonID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	l := s.lesson(lessonID)
+	if l == nil {
+		writeStatus(w, http.StatusNotFound, "Les niet gevonden")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if m.booked[lessonID] {
+			writeStatus(w, http.StatusConflict, "Je hebt deze les al geboekt")
+			return
+		}
+
+		if l.SpotsAvailable == 0 {
+			writeStatus(w, http.StatusConflict, "Les is vol")
+			return
+		}
+
+		l.SpotsAvailable--
+		m.booked[lessonID] = true
+	case http.MethodDelete:
+		if !m.booked[lessonID] {
+			writeStatus(w, http.StatusNotFound, "Je hebt deze les niet geboekt")
+			return
+		}
+
+		l.SpotsAvailable++
+		delete(m.booked, lessonID)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	writeStatus(w, http.StatusOK, "OK")
+}
+
+// authenticate returns the member of the bearer token, nil without a token
+// It responds with 401 and returns false when the token is invalid
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*member, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, true
+	}
+
+	s.lock.Lock()
+	m, ok := s.tokens[strings.TrimPrefix(header, "Bearer ")]
+	s.lock.Unlock()
+
+	if !ok {
+		writeStatus(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	return m, true
+}
+
+// login creates a new token for the member and sets it on the member's user, the lock must be held
+func (s *Server) login(m *member) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.tokens[token] = m
+	m.user.SessionID = token
+	return token
+}
+
+// lesson returns the lesson with the given id, the lock must be held
+func (s *Server) lesson(lessonID string) *lesson {
+	for _, l := range s.lessons {
+		if l.ID == lessonID {
+			return l
+		}
+	}
+	return nil
+}
+
+func writeStatus(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(struct{ Status fitforfree.Status }{fitforfree.Status{Code: uint(statusCode), Message: message}})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,78 @@
+package fitforfree
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the amount of requests made, share one between clients to limit the whole process
+type RateLimiter struct {
+	perSecond float64
+	burst     int
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter that allows perSecond requests on average and bursts of up to burst requests
+// perSecond must be a positive number and burst at least 1
+func NewRateLimiter(perSecond float64, burst int) (*RateLimiter, error) {
+	if !(perSecond > 0) || math.IsInf(perSecond, 1) {
+		return nil, fmt.Errorf("rate must be a positive number of requests per second, got %v", perSecond)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("burst must be at least 1 request, got %d", burst)
+	}
+
+	return &RateLimiter{
+		perSecond: perSecond,
+		burst:     burst,
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}, nil
+}
+
+// Wait blocks until a request can be made or ctx is done
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	r.lock.Lock()
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.perSecond
+	if r.tokens > float64(r.burst) {
+		r.tokens = float64(r.burst)
+	}
+	r.last = now
+
+	// Reserve a token, when there are none we wait until ours is refilled
+	r.tokens--
+	var wait time.Duration
+	if r.tokens < 0 {
+		wait = time.Duration(-r.tokens / r.perSecond * float64(time.Second))
+	}
+	r.lock.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the token we reserved
+		r.lock.Lock()
+		r.tokens++
+		r.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (r *RateLimiter) String() string {
+	return fmt.Sprintf("%.2f requests per second with bursts of %d", r.perSecond, r.burst)
+}
@@ -0,0 +1,278 @@
+package fitforfree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// scrubbed replaces the values of secret fields in recorded fixtures
+const scrubbed = "REDACTED"
+
+// secretFields are the json keys, lowercased, whose values are scrubbed from fixtures
+var secretFields = map[string]bool{
+	"memberid":         true,
+	"postcode":         true,
+	"sessionid":        true,
+	"memberuuid":       true,
+	"membershipnumber": true,
+	"cardnumber":       true,
+	"firstname":        true,
+	"surname":          true,
+	"nameprefix":       true,
+	"email":            true,
+	"phone":            true,
+	"cellphone":        true,
+	"picture":          true,
+}
+
+// loginFields are the json keys, lowercased, of the user in the login response that are recorded
+// The rest of the user is personal and not needed to decode the response, so it is left out of fixtures
+var loginFields = map[string]bool{
+	"sessionid":           true,
+	"canbookgrouplessons": true,
+	"expired":             true,
+	"has_group_lessons":   true,
+}
+
+// unsafeFileChars are replaced in fixture file names
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// Fixture is a recorded request and the response the api gave
+type Fixture struct {
+	Method       string
+	URL          string
+	RequestBody  json.RawMessage `json:",omitempty"`
+	StatusCode   int
+	RetryAfter   string          `json:",omitempty"`
+	ResponseBody json.RawMessage `json:",omitempty"`
+}
+
+// RecordingTransport is a http.RoundTripper that writes every request and response to a fixture file in Dir
+// Secrets like tokens, credentials and personal details are scrubbed before writing
+type RecordingTransport struct {
+	Dir string
+	// Transport executes the requests, http.DefaultTransport when nil
+	Transport http.RoundTripper
+
+	lock  sync.Mutex
+	count int
+}
+
+// RoundTrip executes the request and records it
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	fixture := Fixture{
+		Method:       req.Method,
+		URL:          req.URL.RequestURI(),
+		RequestBody:  scrub(reqBody),
+		StatusCode:   res.StatusCode,
+		RetryAfter:   res.Header.Get("Retry-After"),
+		ResponseBody: scrub(resBody),
+	}
+	if strings.HasSuffix(req.URL.Path, "/login") {
+		fixture.ResponseBody = scrubLogin(resBody)
+	}
+
+	if err := t.write(fixture); err != nil {
+		return nil, fmt.Errorf("fitforfree: can't record fixture: %w", err)
+	}
+
+	return res, nil
+}
+
+func (t *RecordingTransport) write(fixture Fixture) error {
+	t.lock.Lock()
+	t.count++
+	count := t.count
+	t.lock.Unlock()
+
+	if err := os.MkdirAll(t.Dir, 0700); err != nil {
+		return err
+	}
+
+	j, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%04d_%s_%s.json", count, fixture.Method, unsafeFileChars.ReplaceAllString(strings.SplitN(fixture.URL, "?", 2)[0], "_"))
+	return ioutil.WriteFile(filepath.Join(t.Dir, name), j, 0600)
+}
+
+// ReplayTransport is a http.RoundTripper serving the fixtures in Dir instead of executing requests
+// Requests are matched on method and url, falling back to the most recent fixture for the method and path
+type ReplayTransport struct {
+	Dir string
+
+	once    sync.Once
+	loadErr error
+	byURL   map[string]Fixture
+	byPath  map[string]Fixture
+}
+
+// RoundTrip returns the recorded response for the request, or 404 when nothing was recorded for it
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.once.Do(t.load)
+	if t.loadErr != nil {
+		return nil, t.loadErr
+	}
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	fixture, ok := t.byURL[req.Method+" "+req.URL.RequestURI()]
+	if !ok {
+		fixture, ok = t.byPath[req.Method+" "+req.URL.Path]
+	}
+
+	if !ok {
+		fixture = Fixture{StatusCode: http.StatusNotFound}
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if fixture.RetryAfter != "" {
+		header.Set("Retry-After", fixture.RetryAfter)
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(fixture.StatusCode),
+		StatusCode: fixture.StatusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(fixture.ResponseBody)),
+		Request:    req,
+	}, nil
+}
+
+// load reads all fixtures in Dir, in file name order so later recordings win
+func (t *ReplayTransport) load() {
+	t.byURL = make(map[string]Fixture)
+	t.byPath = make(map[string]Fixture)
+
+	files, err := filepath.Glob(filepath.Join(t.Dir, "*.json"))
+	if err != nil {
+		t.loadErr = err
+		return
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+
+		fixture := Fixture{}
+		if err := json.Unmarshal(b, &fixture); err != nil {
+			t.loadErr = fmt.Errorf("fitforfree: invalid fixture %s: %w", file, err)
+			return
+		}
+
+		t.byURL[fixture.Method+" "+fixture.URL] = fixture
+		t.byPath[fixture.Method+" "+strings.SplitN(fixture.URL, "?", 2)[0]] = fixture
+	}
+}
+
+// scrub replaces the values of secret fields in the json body, bodies that are not json are dropped
+func scrub(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+
+	scrubbedBody, err := json.Marshal(scrubValue(v))
+	if err != nil {
+		return nil
+	}
+	return scrubbedBody
+}
+
+// scrubLogin scrubs the login response and leaves everything but the loginFields out of the user in data
+func scrubLogin(body []byte) json.RawMessage {
+	var res map[string]interface{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return scrub(body)
+	}
+
+	for name, data := range res {
+		user, ok := data.(map[string]interface{})
+		if !ok || strings.ToLower(name) != "data" {
+			continue
+		}
+
+		for key := range user {
+			if !loginFields[strings.ToLower(key)] {
+				delete(user, key)
+			}
+		}
+	}
+
+	scrubbedBody, err := json.Marshal(scrubValue(res))
+	if err != nil {
+		return nil
+	}
+	return scrubbedBody
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if secretFields[strings.ToLower(key)] {
+				value[key] = scrubbed
+				continue
+			}
+			value[key] = scrubValue(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = scrubValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package fitforfree_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree/fitforfreetest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("123", "1234AB", fitforfree.User{FirstName: "Secret", Email: "secret@example.com", Gender: "x", Weight: 7531, PreferredVenueID: "home-venue"})
+	server.AddLesson(fitforfree.Lesson{ID: "1", VenueName: "venue", StartTimestamp: 150, SpotsAvailable: 3})
+
+	dir := t.TempDir()
+	recorder := server.Client(fitforfree.Config{RecordDir: dir})
+
+	user, err := recorder.Login(context.Background(), "123", "1234AB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := recorder.GetLessons(context.Background(), 100, 200, []string{"venue"}, user.SessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 fixtures, got %d", len(files))
+	}
+
+	for _, file := range files {
+		b, _ := ioutil.ReadFile(file)
+		for _, secret := range []string{"123", "1234AB", "Secret", "secret@example.com", user.SessionID, `"gender"`, "7531", "home-venue"} {
+			if strings.Contains(string(b), secret) {
+				t.Errorf("Fixture %s contains secret %s", file, secret)
+			}
+		}
+	}
+
+	// The server is not needed to replay
+	server.Close()
+	replayer := fitforfree.NewClient(fitforfree.Config{ReplayDir: dir})
+
+	replayedUser, err := replayer.Login(context.Background(), "123", "1234AB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replayedUser.SessionID != "REDACTED" {
+		t.Errorf("Expected scrubbed session id, got %s", replayedUser.SessionID)
+	}
+
+	// Other timeframes fall back to the recording for the path
+	lessons, err := replayer.GetLessons(context.Background(), 300, 400, []string{"venue"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lessons) != 1 || lessons[0].SpotsAvailable != 3 {
+		t.Errorf("Expected the recorded lesson, got %+v", lessons)
+	}
+}
+
+// TestDecodeFixtures decodes the fixtures in testdata/replay, see testdata/replay/README.md
+// The recordings of the real api are decoded when they are there, the synthetic fixtures always
+func TestDecodeFixtures(t *testing.T) {
+	decodeFixtures(t, "testdata/replay/synthetic", true)
+
+	if _, err := os.Stat("testdata/replay/recorded"); os.IsNotExist(err) {
+		t.Log("No recordings of the real api in testdata/replay/recorded, only the synthetic fixtures are decoded")
+		return
+	}
+	decodeFixtures(t, "testdata/replay/recorded", false)
+}
+
+// decodeFixtures decodes the login, venues and lessons fixtures in dir
+// The user of a recording is who recorded it, so only synthetic users have to be able to book group lessons
+func decodeFixtures(t *testing.T, dir string, synthetic bool) {
+	client := fitforfree.NewClient(fitforfree.Config{ReplayDir: dir})
+
+	user, err := client.Login(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("%s: %v", dir, err)
+	}
+
+	if user.SessionID == "" || (synthetic && (user.CanBookGroupLessons == 0 || !user.HasGroupLessons)) {
+		t.Errorf("%s: user not decoded completely: %+v", dir, user)
+	}
+
+	venues, err := client.GetAllVenues(context.Background(), "")
+	if err != nil {
+		t.Fatalf("%s: %v", dir, err)
+	}
+
+	if len(venues) == 0 || venues[0].ID == "" || venues[0].Name == "" {
+		t.Errorf("%s: venues not decoded completely: %+v", dir, venues)
+	}
+
+	// Replaying falls back to the recorded lessons of any day and venue
+	lessons, err := client.GetLessons(context.Background(), 1607036399, 1607122801, []string{"ffe0e5e2-5b5e-4d0a-9c43-3f6f3f0d1a01"}, "")
+	if err != nil {
+		t.Fatalf("%s: %v", dir, err)
+	}
+
+	if len(lessons) == 0 {
+		t.Fatalf("%s: no lessons decoded", dir)
+	}
+
+	for _, lesson := range lessons {
+		if lesson.ID == "" || lesson.StartTimestamp == 0 || lesson.DurationSeconds == 0 || lesson.Activity.Name == "" || lesson.ClassType == "" || lesson.Capacity == 0 {
+			t.Errorf("%s: lesson not decoded completely: %+v", dir, lesson)
+		}
+	}
+}
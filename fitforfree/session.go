@@ -0,0 +1,164 @@
+package fitforfree
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TokenStore persists the bearer token of a session so it survives restarts
+type TokenStore interface {
+	LoadToken() (string, error)
+	SaveToken(token string) error
+}
+
+// FileTokenStore is a TokenStore that keeps the token in the file at the given path
+type FileTokenStore string
+
+// LoadToken reads the token from the file, a missing file means there is no token yet
+func (f FileTokenStore) LoadToken() (string, error) {
+	token, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// SaveToken writes the token to the file, only readable by the current user
+func (f FileTokenStore) SaveToken(token string) error {
+	return ioutil.WriteFile(string(f), []byte(token), 0600)
+}
+
+// Session makes authenticated requests with a bearer token, logging in again when the token expires
+type Session struct {
+	client     *Client
+	memberID   string
+	postalCode string
+	store      TokenStore
+
+	// OnLoginError is called with the error when logging in fails
+	OnLoginError func(error)
+
+	lock  sync.Mutex
+	token string
+}
+
+// NewSession returns a session that logs in with the given credentials, store can be nil to keep the token in memory
+func (c *Client) NewSession(memberID string, postalCode string, store TokenStore) *Session {
+	return &Session{
+		client:     c,
+		memberID:   memberID,
+		postalCode: postalCode,
+		store:      store,
+	}
+}
+
+// Client returns the client the session makes it's requests with
+func (s *Session) Client() *Client {
+	return s.client
+}
+
+// Token returns the current bearer token, loading it from the store or logging in when there is none
+func (s *Session) Token(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" {
+		return s.token, nil
+	}
+
+	if s.store != nil {
+		token, err := s.store.LoadToken()
+		if err != nil {
+			return "", err
+		}
+
+		if token != "" {
+			s.token = token
+			return token, nil
+		}
+	}
+
+	return s.login(ctx)
+}
+
+// renew logs in again unless the expired token was already replaced by another request
+func (s *Session) renew(ctx context.Context, expired string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" && s.token != expired {
+		return s.token, nil
+	}
+
+	return s.login(ctx)
+}
+
+// login retrieves and stores a new token, the lock must be held
+func (s *Session) login(ctx context.Context) (string, error) {
+	user, err := s.client.Login(ctx, s.memberID, s.postalCode)
+	if err != nil {
+		if s.OnLoginError != nil {
+			s.OnLoginError(err)
+		}
+		return "", err
+	}
+
+	s.token = user.SessionID
+
+	if s.store != nil {
+		if err := s.store.SaveToken(s.token); err != nil {
+			return "", err
+		}
+	}
+
+	return s.token, nil
+}
+
+// Do calls fn with the bearer token, when fn returns ErrUnauthorized it logs in again and calls fn once more
+func (s *Session) Do(ctx context.Context, fn func(token string) error) error {
+	token, err := s.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(token)
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+
+	token, err = s.renew(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return fn(token)
+}
+
+// GetLessons gets lessons between 2 timestamps, see Client.GetLessons
+func (s *Session) GetLessons(ctx context.Context, start uint, end uint, venues []string) ([]Lesson, error) {
+	var lessons []Lesson
+	err := s.Do(ctx, func(token string) error {
+		var err error
+		lessons, err = s.client.GetLessons(ctx, start, end, venues, token)
+		return err
+	})
+	return lessons, err
+}
+
+// GetAllVenues gets all venues, see Client.GetAllVenues
+func (s *Session) GetAllVenues(ctx context.Context) ([]Venue, error) {
+	var venues []Venue
+	err := s.Do(ctx, func(token string) error {
+		var err error
+		venues, err = s.client.GetAllVenues(ctx, token)
+		return err
+	})
+	return venues, err
+}
@@ -0,0 +1,86 @@
+package fitforfree
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionRenewsExpiredToken(t *testing.T) {
+	logins := 0
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v0/login" {
+			logins++
+			w.Write([]byte(`{"status":{"code":200},"data":{"sessionid":"new"}}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(401)
+			return
+		}
+
+		w.Write([]byte(`{"status":{"code":200},"data":{"lessons":[{"id":"1"}]}}`))
+	})
+	defer server.Close()
+
+	store := FileTokenStore(filepath.Join(t.TempDir(), "token"))
+	if err := store.SaveToken("expired"); err != nil {
+		t.Fatal(err)
+	}
+
+	session := client.NewSession("1", "1234AB", store)
+	lessons, err := session.GetLessons(context.Background(), 1, 2, []string{"venue"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(lessons) != 1 {
+		t.Error("Did not get lessons after renewing token")
+	}
+
+	if logins != 1 {
+		t.Errorf("Expected 1 login, got %d", logins)
+	}
+
+	token, err := store.LoadToken()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if token != "new" {
+		t.Error("New token is not persisted")
+	}
+
+	// A new session should use the persisted token without logging in
+	if _, err := client.NewSession("1", "1234AB", store).GetLessons(context.Background(), 1, 2, []string{"venue"}); err != nil {
+		t.Error(err)
+	}
+
+	if logins != 1 {
+		t.Error("Logged in while there was a persisted token")
+	}
+}
+
+func TestSessionLoginError(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+	})
+	defer server.Close()
+
+	var loginErr error
+	session := client.NewSession("1", "1234AB", nil)
+	session.OnLoginError = func(err error) {
+		loginErr = err
+	}
+
+	if _, err := session.GetLessons(context.Background(), 1, 2, []string{"venue"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %+v", err)
+	}
+
+	if !errors.Is(loginErr, ErrUnauthorized) {
+		t.Error("OnLoginError not called with the login error")
+	}
+}
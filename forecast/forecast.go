@@ -0,0 +1,115 @@
+// Package forecast estimates how likely a spot opens in a full lesson based on the availability history of similar lessons
+package forecast
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+)
+
+const (
+	// History is how far back similar lessons are looked for
+	History = time.Hour * 24 * 7 * 12
+	// MinLessons is the amount of similar full lessons needed for an estimate
+	MinLessons = 3
+	// timeOfDayMargin is how far the start of a similar lesson may be from the lesson on its day
+	timeOfDayMargin = time.Minute * 30
+)
+
+// location is the timezone lessons are compared in, so a weekday and time of day mean the same all year
+var location, _ = time.LoadLocation("Europe/Amsterdam")
+
+// Estimate is how likely a spot opens in a full lesson, and when it usually happens
+type Estimate struct {
+	// Lessons is the amount of similar lessons that were full at some point
+	Lessons int
+	// Opened is the amount of those lessons in which a spot opened
+	Opened int
+	// From and To are the range in which spots usually opened before the start of the lesson
+	From time.Duration
+	To   time.Duration
+}
+
+// Enough returns if there were enough similar lessons for the estimate to mean something
+func (e Estimate) Enough() bool {
+	return e.Lessons >= MinLessons
+}
+
+// Probability returns the fraction of similar full lessons in which a spot opened
+func (e Estimate) Probability() float64 {
+	if e.Lessons == 0 {
+		return 0
+	}
+	return float64(e.Opened) / float64(e.Lessons)
+}
+
+// For estimates the lesson starting at start from the observations of its activity
+// Observations of lessons on another weekday or time of day are ignored, observations should be sorted by lesson and time
+func For(observations []database.Observation, start time.Time) Estimate {
+	similar := make([]database.Observation, 0)
+	for _, observation := range observations {
+		if isSimilar(time.Unix(int64(observation.LessonStart), 0), start) {
+			similar = append(similar, observation)
+		}
+	}
+
+	// Lessons that were never full can't get a spot opened
+	full := make(map[string]bool)
+	for _, observation := range similar {
+		if observation.SpotsAvailable == 0 && observation.ObservedAt.Unix() < int64(observation.LessonStart) {
+			full[observation.LessonID] = true
+		}
+	}
+
+	opened := make(map[string]bool)
+	leads := make([]time.Duration, 0)
+	for _, opening := range database.FindOpenings(similar) {
+		opened[opening.LessonID] = true
+		leads = append(leads, opening.Lead())
+	}
+
+	estimate := Estimate{Lessons: len(full), Opened: len(opened)}
+	if len(leads) > 0 {
+		sort.Slice(leads, func(i, j int) bool {
+			return leads[i] < leads[j]
+		})
+		estimate.From = percentile(leads, 0.25)
+		estimate.To = percentile(leads, 0.75)
+	}
+
+	return estimate
+}
+
+// isSimilar returns if the lessons start on the same weekday around the same time of day
+func isSimilar(a time.Time, b time.Time) bool {
+	a = a.In(location)
+	b = b.In(location)
+	if a.Weekday() != b.Weekday() {
+		return false
+	}
+
+	difference := timeOfDay(a) - timeOfDay(b)
+	if difference < 0 {
+		difference = -difference
+	}
+	return difference <= timeOfDayMargin
+}
+
+// timeOfDay returns the time since midnight
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// percentile returns the nearest rank percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,65 @@
+package forecast
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/database"
+)
+
+// history builds observations of a lesson starting at start, with the spots available at every lead time before the start
+type history struct {
+	observations []database.Observation
+}
+
+func (h *history) lesson(start time.Time, spots map[time.Duration]uint8, leads ...time.Duration) {
+	id := fmt.Sprint(start.Unix())
+	for _, lead := range leads {
+		h.observations = append(h.observations, database.Observation{
+			LessonID:       id,
+			LessonStart:    uint(start.Unix()),
+			ObservedAt:     start.Add(-lead),
+			SpotsAvailable: spots[lead],
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	// Monday 18:00
+	monday := time.Date(2030, 1, 7, 18, 0, 0, 0, location)
+	week := time.Hour * 24 * 7
+	leads := []time.Duration{time.Hour * 24, time.Hour * 8, time.Hour * 6, time.Hour * 4, time.Hour * 2, time.Hour}
+
+	h := &history{}
+	// Full lessons where a spot opened 6, 4 and 2 hours before
+	h.lesson(monday.Add(-week), map[time.Duration]uint8{time.Hour * 6: 1}, leads...)
+	h.lesson(monday.Add(-week*2), map[time.Duration]uint8{time.Hour * 4: 2}, leads...)
+	h.lesson(monday.Add(-week*3+time.Minute*15), map[time.Duration]uint8{time.Hour * 2: 1}, leads...)
+	// A full lesson where no spot opened
+	h.lesson(monday.Add(-week*4), map[time.Duration]uint8{}, leads...)
+	// A lesson that was never full
+	h.lesson(monday.Add(-week*5), map[time.Duration]uint8{time.Hour * 24: 5, time.Hour * 8: 5, time.Hour * 6: 5, time.Hour * 4: 5, time.Hour * 2: 5, time.Hour: 5}, leads...)
+	// Full lessons at another time and on another day that opened
+	h.lesson(monday.Add(-week-time.Hour*6), map[time.Duration]uint8{time.Hour: 1}, leads...)
+	h.lesson(monday.Add(-week+time.Hour*24), map[time.Duration]uint8{time.Hour: 1}, leads...)
+
+	estimate := For(h.observations, monday)
+
+	if !estimate.Enough() {
+		t.Fatalf("Expected enough similar lessons, got %+v", estimate)
+	}
+
+	if estimate.Lessons != 4 || estimate.Opened != 3 || estimate.Probability() != 0.75 {
+		t.Errorf("Expected spots to open in 3 of 4 lessons, got %+v", estimate)
+	}
+
+	if estimate.From != time.Hour*2 || estimate.To != time.Hour*6 {
+		t.Errorf("Expected spots to open 2 to 6 hours before, got %s to %s", estimate.From, estimate.To)
+	}
+
+	// Not enough history on fridays
+	if estimate := For(h.observations, monday.Add(time.Hour*24*4)); estimate.Enough() {
+		t.Errorf("Expected not enough history on friday, got %+v", estimate)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/joho/godotenv v1.3.0
 	github.com/laytan/gotermux v0.1.0
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	gorm.io/driver/postgres v1.0.6
 	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.20.8
 )
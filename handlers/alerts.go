@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/laytan/go-fff-notifications-bot/bot"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/locale"
+	"github.com/laytan/go-fff-notifications-bot/times"
+)
+
+// alertsHistory is how far back /alerts shows who was alerted
+const alertsHistory = time.Hour * 24
+
+// AlertsHandler shows admins who was alerted of the spots that opened in the last day and in what order
+func AlertsHandler(repository database.AlertRepository) func(*bot.HandlePayload, []string) {
+	return func(p *bot.HandlePayload, _ []string) {
+		if !p.User.Admin() {
+			p.Respond(p.T("Dit commando is alleen voor admins."))
+			return
+		}
+
+		alerts, err := repository.Since(context.Background(), time.Now().Add(-alertsHistory))
+		if err != nil {
+			log.Printf("ERROR: Error retrieving alerts for AlertsHandler, err: %+v", err)
+			p.Respond(p.T("Er ging iets fout, probeer het opnieuw"))
+			return
+		}
+
+		if len(alerts) == 0 {
+			p.Respond(p.T("Er is het afgelopen etmaal niemand gealarmeerd."))
+			return
+		}
+
+		p.Respond(formatAlerts(p.User.Settings, alerts))
+	}
+}
+
+// formatAlerts formats alerts, sorted by time and lesson, with a header for every time a lesson opened
+func formatAlerts(settings database.UserSettings, alerts []database.Alert) string {
+	loc := settings.Location()
+	msg := ""
+	for i, alert := range alerts {
+		if i == 0 || alert.LessonID != alerts[i-1].LessonID || !alert.AlertedAt.Equal(alerts[i-1].AlertedAt) {
+			msg += fmt.Sprintf(
+				locale.T(settings.Language, "\n%s %s %s, %d plek(ken) om %s (%s):\n"),
+				alert.LessonName,
+				times.FormatTimestampIn(alert.LessonStart, times.DateLayout, loc),
+				times.FormatTimestampIn(alert.LessonStart, times.TimeLayout, loc),
+				alert.Spots,
+				times.FormatTimestampIn(uint(alert.AlertedAt.Unix()), times.TimeLayout, loc),
+				alert.Policy,
+			)
+		}
+
+		msg += fmt.Sprintf(locale.T(settings.Language, "%d. %s na %s"), alert.Position+1, alert.User.Name, alert.Delay)
+		if alert.Booked {
+			msg += locale.T(settings.Language, ", geboekt")
+		}
+		msg += "\n"
+	}
+
+	return msg
+}
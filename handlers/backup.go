@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/laytan/go-fff-notifications-bot/bot"
+	"github.com/laytan/go-fff-notifications-bot/database"
+)
+
+// BackupHandler takes a backup of the database right away and sends it to the admin as a document
+func BackupHandler(backups *database.Backups) func(*bot.HandlePayload, []string) {
+	return func(p *bot.HandlePayload, _ []string) {
+		if !p.User.Admin() {
+			p.Respond(p.T("Dit commando is alleen voor admins."))
+			return
+		}
+
+		path, err := backups.BackupOnce(context.Background())
+		if errors.Is(err, database.ErrBackupUnsupported) {
+			p.Respond(p.T("Back-ups kunnen alleen van een sqlite database gemaakt worden, gebruik pg_dump voor PostgreSQL."))
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: Error backing up the database for BackupHandler, err: %+v", err)
+			p.Respond(p.T("Er ging iets fout bij het maken van de back-up, probeer het opnieuw."))
+			return
+		}
+
+		document := tgbotapi.NewDocumentUpload(p.ChatID(), path)
+		if _, err := p.Bot.Send(document); err != nil {
+			log.Printf("ERROR: Error sending backup %s, err: %+v", path, err)
+			p.Respond(p.T("De back-up is gemaakt maar kon niet verstuurd worden, hij staat op de server."))
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/laytan/go-fff-notifications-bot/bot"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/locale"
+	"github.com/laytan/go-fff-notifications-bot/times"
+)
+
+// bookingsDays is how many days ahead /mybookings looks for booked lessons
+const bookingsDays = 14
+
+// MyBookingsHandler lists the lessons the user has booked with a button to cancel each of them
+func MyBookingsHandler(client *fitforfree.Client, sealer *database.Sealer) func(*bot.HandlePayload, []string) {
+	return func(p *bot.HandlePayload, _ []string) {
+		token, err := p.User.Token(sealer)
+		if err != nil {
+			p.Respond(fmt.Sprintf(p.T("Je boekingen kunnen niet opgehaald worden, %s."), BookingErrorReason(p.User.Settings.Language, err)))
+			return
+		}
+
+		now := uint(time.Now().Unix())
+		lessons, err := client.GetLessons(context.Background(), now, now+60*60*24*bookingsDays, p.User.Venues(), token)
+		if err != nil {
+			log.Printf("ERROR: Error getting lessons in MyBookingsHandler, user: %d, err: %+v", p.User.ID, err)
+			p.Respond(fmt.Sprintf(p.T("Je boekingen kunnen niet opgehaald worden, %s."), BookingErrorReason(p.User.Settings.Language, err)))
+			return
+		}
+
+		booked := fitforfree.Filter(lessons, func(lesson fitforfree.Lesson) bool {
+			return lesson.Booked
+		})
+
+		if len(booked) == 0 {
+			p.Respond(p.T("Je hebt geen lessen geboekt."))
+			return
+		}
+
+		msg := p.T("Je geboekte lessen:")
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(booked))
+		for i, lesson := range booked {
+			msg += formatBooking(p.User.Settings, lesson, uint(i))
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf(p.T("Annuleer %d"), i), fmt.Sprintf("cancel|%s", lesson.ID)),
+			))
+		}
+
+		reply := tgbotapi.NewMessage(p.ChatID(), msg)
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		p.Bot.Send(reply)
+	}
+}
+
+// BookHandler books the lesson with the given id for the user and removes their noti for it
+func BookHandler(notis database.NotiRepository, client *fitforfree.Client, sealer *database.Sealer) func(*bot.HandlePayload, string) {
+	return func(p *bot.HandlePayload, lessonID string) {
+		token, err := p.User.Token(sealer)
+		if err != nil {
+			p.Answer(p.T("Boeken is mislukt"))
+			p.Respond(fmt.Sprintf(p.T("Boeken is mislukt, %s."), BookingErrorReason(p.User.Settings.Language, err)))
+			return
+		}
+
+		if err := client.BookLesson(context.Background(), lessonID, token); err != nil {
+			log.Printf("ERROR: Error booking lesson %s, user: %d, err: %+v", lessonID, p.User.ID, err)
+			p.Answer(p.T("Boeken is mislukt"))
+			p.Respond(fmt.Sprintf(p.T("Boeken is mislukt, %s."), BookingErrorReason(p.User.Settings.Language, err)))
+			return
+		}
+
+		// The user has the lesson so notifying is not needed anymore
+		if err := notis.DeleteByLesson(context.Background(), p.User.ID, lessonID); err != nil {
+			log.Printf("ERROR: Error removing noti of booked lesson %s, user: %d, err: %+v", lessonID, p.User.ID, err)
+		}
+
+		p.Answer("")
+		p.Respond(p.T("De les is geboekt!"))
+	}
+}
+
+// CancelBookingHandler cancels the user's booking of the lesson with the given id
+func CancelBookingHandler(client *fitforfree.Client, sealer *database.Sealer) func(*bot.HandlePayload, string) {
+	return func(p *bot.HandlePayload, lessonID string) {
+		token, err := p.User.Token(sealer)
+		if err != nil {
+			p.Answer(p.T("Annuleren is mislukt"))
+			p.Respond(fmt.Sprintf(p.T("Annuleren is mislukt, %s."), BookingErrorReason(p.User.Settings.Language, err)))
+			return
+		}
+
+		if err := client.CancelBooking(context.Background(), lessonID, token); err != nil {
+			log.Printf("ERROR: Error cancelling lesson %s, user: %d, err: %+v", lessonID, p.User.ID, err)
+			p.Answer(p.T("Annuleren is mislukt"))
+			p.Respond(fmt.Sprintf(p.T("Annuleren is mislukt, %s."), BookingErrorReason(p.User.Settings.Language, err)))
+			return
+		}
+
+		p.Answer("")
+		p.Respond(p.T("De les is geannuleerd."))
+	}
+}
+
+// formatBooking formats a booked lesson for display
+func formatBooking(settings database.UserSettings, lesson fitforfree.Lesson, id uint) string {
+	loc := settings.Location()
+	return fmt.Sprintf(locale.T(settings.Language, `
+		Nummer: %d
+		Activiteit: %s
+		Datum: %s
+		Start: %s
+		Eind: %s`),
+		id,
+		lesson.Activity.Name,
+		times.FormatTimestampIn(lesson.StartTimestamp, times.DateLayout, loc),
+		times.FormatTimestampIn(lesson.StartTimestamp, times.TimeLayout, loc),
+		times.FormatTimestampIn(lesson.StartTimestamp+lesson.DurationSeconds, times.TimeLayout, loc),
+	)
+}
@@ -1,177 +1,270 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/laytan/go-fff-notifications-bot/bot"
 	"github.com/laytan/go-fff-notifications-bot/database"
 	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/forecast"
+	"github.com/laytan/go-fff-notifications-bot/locale"
 	"github.com/laytan/go-fff-notifications-bot/times"
-	"gorm.io/gorm"
 )
 
 // HelpHandler responds with the a message
 func HelpHandler(p *bot.HandlePayload, _ []string) {
-	p.Respond(
-		`
-		Te gebruiken commandos:
-		- /noti: Start een gesprek om een nieuwe notificatie toe te voegen
-		- /notifications: Verkrijg een lijst met alle ingestelde notificaties
-		- /clear: Verwijder al je notificaties
-		- /remove {nummer}: Verwijder de notificatie met het gegeven nummer 
-		`,
-	)
+	p.Respond(strings.Join([]string{
+		p.T("Te gebruiken commandos:"),
+		p.T("- /noti: Start een gesprek om een nieuwe notificatie toe te voegen"),
+		p.T("- /notifications: Verkrijg een lijst met alle ingestelde notificaties"),
+		p.T("- /clear: Verwijder al je notificaties"),
+		p.T("- /remove {nummer}: Verwijder de notificatie met het gegeven nummer"),
+		p.T("- /mybookings: Bekijk en annuleer je geboekte lessen"),
+		p.T("- /login: Koppel je FitForFree account om lessen te boeken"),
+		p.T("- /logout: Ontkoppel je FitForFree account"),
+		p.T("- /venues {zoekterm}: Bekijk je sportscholen of zoek er een om toe te voegen"),
+		p.T("- /settings: Bekijk en wijzig je instellingen"),
+	}, "\n"))
 }
 
 // ListNotisHandler forwards the update to either ListNotisAdminHandler or ListNotisNormalHandler based on the update's user
-func ListNotisHandler(db *gorm.DB) func(*bot.HandlePayload, []string) {
+func ListNotisHandler(notis database.NotiRepository) func(*bot.HandlePayload, []string) {
 	return func(p *bot.HandlePayload, _ []string) {
 		if p.User.Admin() {
-			ListNotisAdminHandler(db, p)
+			ListNotisAdminHandler(notis, p)
 			return
 		}
 
-		ListNotisNormalHandler(db, p)
+		ListNotisNormalHandler(notis, p)
 	}
 }
 
 // ListNotisAdminHandler lists all the notis in the database
 // NOTE: Should only be used when the payload has an admin user
-func ListNotisAdminHandler(db *gorm.DB, p *bot.HandlePayload) {
+func ListNotisAdminHandler(repository database.NotiRepository, p *bot.HandlePayload) {
 	if !p.User.Admin() {
 		panic("Called ListNotisAdminHandler without admin user")
 	}
 
 	msg := ""
 
-	notis := make([]database.Noti, 0)
-	if err := db.Joins("User").Joins("Lesson").Find(&notis).Error; err != nil {
+	notis, err := repository.All(context.Background())
+	if err != nil {
 		log.Printf("ERROR: Error retrieving all notis from the database for ListNotisAdminHandler, err: %+v", err)
-		p.Respond("Er ging iets fout, probeer het opnieuw")
+		p.Respond(p.T("Er ging iets fout, probeer het opnieuw"))
 		return
 	}
 
 	if len(notis) == 0 {
-		p.Respond("Geen notificaties gevonden")
+		p.Respond(p.T("Geen notificaties gevonden"))
 		return
 	}
 
 	for _, noti := range notis {
-		msg += formatNoti(noti, true)
+		msg += formatNoti(p.User.Settings, noti, true)
 	}
 
 	p.Respond(msg)
 }
 
 // ListNotisNormalHandler lists all the user's notis
-func ListNotisNormalHandler(db *gorm.DB, p *bot.HandlePayload) {
+func ListNotisNormalHandler(repository database.NotiRepository, p *bot.HandlePayload) {
 	msg := ""
 
-	notis := make([]database.Noti, 0)
-	if err := db.Joins("Lesson").Where("user_id = ?", p.User.ID).Find(&notis).Error; err != nil {
+	notis, err := repository.ByUser(context.Background(), p.User.ID)
+	if err != nil {
 		log.Printf("ERROR: Error retrieving users noti's, user: %+v, err: %+v", p.User, err)
-		p.Respond("Er ging iets fout, probeer het opnieuw")
+		p.Respond(p.T("Er ging iets fout, probeer het opnieuw"))
 		return
 	}
 
 	if len(notis) == 0 {
-		p.Respond("Geen notificaties gevonden.")
+		p.Respond(p.T("Geen notificaties gevonden."))
 		return
 	}
 
 	for _, noti := range notis {
-		msg += formatNoti(noti, false)
+		msg += formatNoti(p.User.Settings, noti, false)
 	}
 
 	p.Respond(msg)
 }
 
 // RemoveHandler removes the noti specified if the user is allowed to
-func RemoveHandler(db *gorm.DB) func(*bot.HandlePayload, []string) {
+func RemoveHandler(notis database.NotiRepository) func(*bot.HandlePayload, []string) {
 	return func(p *bot.HandlePayload, args []string) {
 		if len(args) != 1 {
-			p.Respond("Stuur het nummer van de notificatie die verwijdert moet worden mee, zoals: /remove 1")
+			p.Respond(p.T("Stuur het nummer van de notificatie die verwijdert moet worden mee, zoals: /remove 1"))
 			return
 		}
 
 		id, err := strconv.Atoi(args[0])
 		if err != nil {
-			p.Respond("Nummer is niet goed ingevuld")
+			p.Respond(p.T("Nummer is niet goed ingevuld"))
 			return
 		}
 
-		noti := database.Noti{}
-		err = db.First(&noti, id).Error
+		noti, err := notis.Get(context.Background(), uint(id))
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				p.Respond("Er bestaat geen notificatie met dat nummer")
+			if errors.Is(err, database.ErrNotFound) {
+				p.Respond(p.T("Er bestaat geen notificatie met dat nummer"))
 				return
 			}
-			p.Respond("Er ging iets fout bij het ophalen van de notificatie, probeer het opnieuw.")
+			p.Respond(p.T("Er ging iets fout bij het ophalen van de notificatie, probeer het opnieuw."))
 			log.Printf("ERROR: Error retrieving noti in RemoveHandler, err: %+v", err)
 			return
 		}
 
 		if noti.UserID != p.User.ID && !p.User.Admin() {
-			p.Respond("Je kunt deze notificatie niet verwijderen omdat deze door iemand anders is gemaakt")
+			p.Respond(p.T("Je kunt deze notificatie niet verwijderen omdat deze door iemand anders is gemaakt"))
 			return
 		}
 
-		if err := db.Delete(&noti).Error; err != nil {
-			p.Respond("Er ging iets fout bij het verwijderen van de notificatie, probeer het opnieuw.")
+		if err := notis.Delete(context.Background(), noti.ID); err != nil {
+			p.Respond(p.T("Er ging iets fout bij het verwijderen van de notificatie, probeer het opnieuw."))
 			log.Printf("ERROR: Error when removing noti, err: %+v", err)
 			return
 		}
 
-		p.Respond("Notificatie verwijderd")
+		p.Respond(p.T("Notificatie verwijderd"))
 	}
 }
 
 // ClearHandler removes all noti's from a user
-func ClearHandler(db *gorm.DB) func(*bot.HandlePayload, []string) {
+func ClearHandler(notis database.NotiRepository) func(*bot.HandlePayload, []string) {
 	return func(p *bot.HandlePayload, _ []string) {
-		db.Where("user_id = ?", p.User.ID).Delete(&database.Noti{})
-		p.Respond("Notificaties verwijderd")
+		if err := notis.DeleteByUser(context.Background(), p.User.ID); err != nil {
+			log.Printf("ERROR: Error clearing notis of user %d, err: %+v", p.User.ID, err)
+			p.Respond(p.T("Er ging iets fout bij het verwijderen van de notificaties, probeer het opnieuw."))
+			return
+		}
+
+		p.Respond(p.T("Notificaties verwijderd"))
+	}
+}
+
+// BookingErrorReason describes why booking a lesson failed in a way the user understands, in the language
+func BookingErrorReason(language string, err error) string {
+	statusErr := new(fitforfree.StatusError)
+	networkErr := new(fitforfree.NetworkError)
+	switch {
+	case errors.Is(err, database.ErrNoSession):
+		return locale.T(language, "je hebt nog geen FitForFree account gekoppeld, dat kan met /login")
+	case errors.Is(err, fitforfree.ErrUnauthorized):
+		return locale.T(language, "je FitForFree sessie is verlopen, log opnieuw in met /login")
+	case errors.Is(err, fitforfree.ErrUpstream):
+		return locale.T(language, "FitForFree heeft op dit moment problemen")
+	case errors.As(err, &networkErr):
+		return locale.T(language, "FitForFree is niet bereikbaar")
+	case errors.As(err, &statusErr) && statusErr.Message != "":
+		return statusErr.Message
+	default:
+		return locale.T(language, "er ging iets fout")
 	}
 }
 
-func formatLesson(lesson fitforfree.Lesson, id uint) string {
-	return fmt.Sprintf(`
+// formatLesson formats a lesson for display, with its venue when the user has lessons at multiple venues
+func formatLesson(settings database.UserSettings, lesson fitforfree.Lesson, id uint, withVenue bool) string {
+	loc := settings.Location()
+	msg := fmt.Sprintf(locale.T(settings.Language, `
 		Nummer: %d
 		Activiteit: %s
 		Start: %s
-		Eind: %s`,
+		Eind: %s`),
 		id,
 		lesson.Activity.Name,
-		times.FormatTimestamp(lesson.StartTimestamp, times.TimeLayout),
-		times.FormatTimestamp(lesson.StartTimestamp+lesson.DurationSeconds, times.TimeLayout),
+		times.FormatTimestampIn(lesson.StartTimestamp, times.TimeLayout, loc),
+		times.FormatTimestampIn(lesson.StartTimestamp+lesson.DurationSeconds, times.TimeLayout, loc),
 	)
+
+	if withVenue {
+		msg += fmt.Sprintf(locale.T(settings.Language, `
+		Locatie: %s`), lesson.VenueName)
+	}
+
+	return msg
+}
+
+// formatEstimate describes how likely a spot opens, like "meestal komt er 2-6 uur van tevoren plek vrij"
+func formatEstimate(language string, estimate forecast.Estimate) string {
+	if estimate.Opened == 0 {
+		return fmt.Sprintf(locale.T(language, "Bij de laatste %d vergelijkbare volle lessen kwam er geen plek vrij."), estimate.Lessons)
+	}
+
+	return fmt.Sprintf(
+		locale.T(language, "Bij %d van de %d vergelijkbare volle lessen kwam er plek vrij (%.0f%%), meestal komt er %s van tevoren plek vrij."),
+		estimate.Opened,
+		estimate.Lessons,
+		estimate.Probability()*100,
+		formatLeadRange(language, estimate.From, estimate.To),
+	)
+}
+
+// formatLeadRange formats a range of time before a lesson in hours, or minutes when it is shorter than an hour
+func formatLeadRange(language string, from time.Duration, to time.Duration) string {
+	hours := func(d time.Duration) int {
+		return int(d.Round(time.Hour) / time.Hour)
+	}
+	minutes := func(d time.Duration) int {
+		return int(d.Round(time.Minute) / time.Minute)
+	}
+
+	switch {
+	case from >= time.Hour && hours(from) == hours(to):
+		return fmt.Sprintf(locale.T(language, "%d uur"), hours(from))
+	case from >= time.Hour:
+		return fmt.Sprintf(locale.T(language, "%d-%d uur"), hours(from), hours(to))
+	case to < time.Hour && minutes(from) == minutes(to):
+		return fmt.Sprintf(locale.T(language, "%d minuten"), minutes(from))
+	case to < time.Hour:
+		return fmt.Sprintf(locale.T(language, "%d-%d minuten"), minutes(from), minutes(to))
+	default:
+		return fmt.Sprintf(locale.T(language, "%d minuten tot %d uur"), minutes(from), hours(to))
+	}
+}
+
+// formatNotiKind describes what happens when the noti's lesson has a spot available
+func formatNotiKind(language string, noti database.Noti) string {
+	switch {
+	case noti.AutoBook:
+		return locale.T(language, "automatisch boeken")
+	case noti.Watch:
+		return locale.T(language, "blijven volgen")
+	default:
+		return locale.T(language, "eenmalige notificatie")
+	}
 }
 
 // formatNoti formats a notification for display
-func formatNoti(noti database.Noti, withName bool) string {
+func formatNoti(settings database.UserSettings, noti database.Noti, withName bool) string {
 	var msg string
 	if withName {
-		msg = fmt.Sprintf("Naam: %s", noti.User.Name)
+		msg = fmt.Sprintf(locale.T(settings.Language, "Naam: %s"), noti.User.Name)
 	} else {
 		msg = ""
 	}
 
-	msg += fmt.Sprintf(`
+	loc := settings.Location()
+	msg += fmt.Sprintf(locale.T(settings.Language, `
 		Nummer: %d
+		Soort: %s
 		Datum: %s
 		Start: %s
 		Eind: %s
 		Gemaakt: %s
-	`,
+	`),
 		noti.ID,
-		times.FormatTimestamp(uint(noti.Lesson.Start), times.DateLayout),
-		times.FormatTimestamp(uint(noti.Lesson.Start), times.TimeLayout),
-		times.FormatTimestamp(uint(noti.Lesson.Start+noti.Lesson.DurationSeconds), times.TimeLayout),
-		times.FormatTimestamp(uint(noti.CreatedAt.Unix()), times.FullLayout))
+		formatNotiKind(settings.Language, noti),
+		times.FormatTimestampIn(uint(noti.Lesson.Start), times.DateLayout, loc),
+		times.FormatTimestampIn(uint(noti.Lesson.Start), times.TimeLayout, loc),
+		times.FormatTimestampIn(uint(noti.Lesson.Start+noti.Lesson.DurationSeconds), times.TimeLayout, loc),
+		times.FormatTimestampIn(uint(noti.CreatedAt.Unix()), times.FullLayout, loc))
 
 	return msg
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -10,12 +11,18 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/laytan/go-fff-notifications-bot/bot"
 	"github.com/laytan/go-fff-notifications-bot/database"
-	"gorm.io/driver/sqlite"
+	"github.com/laytan/go-fff-notifications-bot/database/databasetest"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree/fitforfreetest"
+	"github.com/laytan/go-fff-notifications-bot/forecast"
+	"github.com/laytan/go-fff-notifications-bot/locale"
+	"github.com/laytan/go-fff-notifications-bot/times"
 	"gorm.io/gorm"
 )
 
 type mockSender struct {
-	OnSend func(tgbotapi.Chattable)
+	OnSend   func(tgbotapi.Chattable)
+	OnAnswer func(tgbotapi.CallbackConfig)
 }
 
 func (m mockSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
@@ -23,23 +30,37 @@ func (m mockSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return tgbotapi.Message{}, nil
 }
 
-func getDB() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("../database/test.sqlite"), &gorm.Config{})
-	if err != nil {
-		panic(err)
+func (m mockSender) AnswerCallbackQuery(c tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	if m.OnAnswer != nil {
+		m.OnAnswer(c)
 	}
-
-	db.AutoMigrate(&database.User{}, &database.Noti{}, &database.Lesson{})
-
-	clearDB(db)
-
-	return db
+	return tgbotapi.APIResponse{Ok: true}, nil
 }
 
+// clearDB deletes the rows tests create, referencing rows first for the foreign keys
 func clearDB(db *gorm.DB) {
-	db.Exec("DELETE FROM users")
 	db.Exec("DELETE FROM notis")
+	db.Exec("DELETE FROM user_settings")
+	db.Exec("DELETE FROM users")
 	db.Exec("DELETE FROM lessons")
+	db.Exec("DELETE FROM observations")
+}
+
+func getSealer() *database.Sealer {
+	sealer, err := database.NewSealer([]byte("01234567890123456789012345678901"))
+	if err != nil {
+		panic(err)
+	}
+	return sealer
+}
+
+// newSessionUser returns a user that has a fitforfree session with the given token
+func newSessionUser(sealer *database.Sealer, id uint, token string) database.User {
+	user := database.User{ID: id}
+	if err := user.SetSession(sealer, fitforfree.User{SessionID: token}); err != nil {
+		panic(err)
+	}
+	return user
 }
 
 func newMockCommandUpdate(command string, args string) tgbotapi.Update {
@@ -58,8 +79,10 @@ func newMockCommandUpdate(command string, args string) tgbotapi.Update {
 }
 
 func TestClearHandler(t *testing.T) {
-	db := getDB()
+	databasetest.Run(t, testClearHandler)
+}
 
+func testClearHandler(t *testing.T, db *gorm.DB) {
 	db.Create(&database.User{ID: 1})
 	db.Create(&[]database.Noti{
 		{
@@ -70,7 +93,7 @@ func TestClearHandler(t *testing.T) {
 		},
 	})
 
-	ClearHandler(db)(&bot.HandlePayload{User: database.User{ID: 1}}, []string{})
+	ClearHandler(database.NewRepositories(db).Notis)(&bot.HandlePayload{User: database.User{ID: 1}}, []string{})
 
 	notis := []database.Noti{}
 	db.Find(&notis)
@@ -90,8 +113,11 @@ type testRemoveHandlerPayload struct {
 }
 
 func TestRemoveHandler(t *testing.T) {
-	db := getDB()
-	handler := RemoveHandler(db)
+	databasetest.Run(t, testRemoveHandler)
+}
+
+func testRemoveHandler(t *testing.T, db *gorm.DB) {
+	handler := RemoveHandler(database.NewRepositories(db).Notis)
 
 	user := database.User{ID: 1}
 
@@ -191,8 +217,11 @@ type testListNotisHandlerPayload struct {
 }
 
 func TestListNotisHandler(t *testing.T) {
-	db := getDB()
-	handler := ListNotisHandler(db)
+	databasetest.Run(t, testListNotisHandler)
+}
+
+func testListNotisHandler(t *testing.T, db *gorm.DB) {
+	handler := ListNotisHandler(database.NewRepositories(db).Notis)
 
 	adminChatID := uint(4)
 	os.Setenv("ADMIN_CHAT_ID", fmt.Sprintf("%d", adminChatID))
@@ -376,7 +405,7 @@ func TestDateNotiHandler(t *testing.T) {
 		},
 	}
 
-	date, continueConv := DateNotiHandler(&handlePayload, nil)
+	date, continueConv := DateNotiHandler(nil)(&handlePayload, nil)
 	if !continueConv {
 		t.Error("Should continue conv here")
 	}
@@ -391,7 +420,7 @@ func TestDateNotiHandler(t *testing.T) {
 
 	// make payload invalid
 	handlePayload.Update.Message.Text = "04-13-2020"
-	_, continueConv = DateNotiHandler(&handlePayload, nil)
+	_, continueConv = DateNotiHandler(nil)(&handlePayload, nil)
 	if continueConv {
 		t.Error("Should not continue conv")
 	}
@@ -411,14 +440,16 @@ func TestTypeNotiHandlerDoesNotRespondToEmptyOrMessage(t *testing.T) {
 		},
 	}
 
-	_, continueConv := TypeNotiHandler(&handlePayload, nil)
+	handler := TypeNotiHandler(nil)
+
+	_, continueConv := handler(&handlePayload, nil)
 	if continueConv {
 		t.Error("Should not continue conv")
 	}
 
 	handlePayload.Update.Message = &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}}
 
-	_, continueConv = TypeNotiHandler(&handlePayload, nil)
+	_, continueConv = handler(&handlePayload, nil)
 	if continueConv {
 		t.Error("Should not continue conv")
 	}
@@ -426,14 +457,622 @@ func TestTypeNotiHandlerDoesNotRespondToEmptyOrMessage(t *testing.T) {
 	handlePayload.Update.Message = nil
 	handlePayload.Update.CallbackQuery = &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}}}
 
-	_, continueConv = TypeNotiHandler(&handlePayload, nil)
+	_, continueConv = handler(&handlePayload, nil)
 	if continueConv {
 		t.Error("Should not continue conv")
 	}
 
 	handlePayload.Update.CallbackQuery.Data = "blablabla"
-	_, continueConv = TypeNotiHandler(&handlePayload, nil)
+	_, continueConv = handler(&handlePayload, nil)
 	if continueConv {
 		t.Error("Should not continue conv")
 	}
 }
+
+type testAutoBookNotiHandlerPayload struct {
+	User     database.User
+	Data     string
+	Continue bool
+	AutoBook bool
+	Watch    bool
+}
+
+func TestAutoBookNotiHandler(t *testing.T) {
+	payloads := []testAutoBookNotiHandlerPayload{
+		{
+			User:     database.User{ID: 1, Session: []byte("sealed")},
+			Data:     "auto_book",
+			Continue: true,
+			AutoBook: true,
+		},
+		{
+			User:     database.User{ID: 1, Session: []byte("sealed")},
+			Data:     "notify",
+			Continue: true,
+			AutoBook: false,
+		},
+		// Can't book without a session
+		{
+			User:     database.User{ID: 1},
+			Data:     "auto_book",
+			Continue: true,
+			AutoBook: false,
+		},
+		{
+			User:     database.User{ID: 1},
+			Data:     "watch",
+			Continue: true,
+			Watch:    true,
+		},
+		{
+			User:     database.User{ID: 1},
+			Data:     "blablabla",
+			Continue: false,
+		},
+	}
+
+	for _, payload := range payloads {
+		handlePayload := bot.HandlePayload{
+			User: payload.User,
+			Bot:  mockSender{OnSend: func(tgbotapi.Chattable) {}},
+			Update: tgbotapi.Update{
+				CallbackQuery: &tgbotapi.CallbackQuery{
+					Data:    payload.Data,
+					Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}},
+				},
+			},
+		}
+
+		options, continueConv := AutoBookNotiHandler(&handlePayload, nil)
+		if continueConv != payload.Continue {
+			t.Errorf("Continue should be %t for %s", payload.Continue, payload.Data)
+		}
+
+		if continueConv && options.(database.NotiOptions).AutoBook != payload.AutoBook {
+			t.Errorf("Auto book should be %t for %s", payload.AutoBook, payload.Data)
+		}
+
+		if continueConv && options.(database.NotiOptions).Watch != payload.Watch {
+			t.Errorf("Watch should be %t for %s", payload.Watch, payload.Data)
+		}
+	}
+}
+
+func newMockUpdate(text string) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text: text,
+			Chat: &tgbotapi.Chat{ID: 1},
+		},
+	}
+}
+
+func newMockCallbackUpdate(data string) tgbotapi.Update {
+	return tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			Data:    data,
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}},
+		},
+	}
+}
+
+func TestBookHandler(t *testing.T) {
+	databasetest.Run(t, testBookHandler)
+}
+
+func testBookHandler(t *testing.T, db *gorm.DB) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("123", "1234AB", fitforfree.User{})
+	server.AddLesson(fitforfree.Lesson{ID: "1", SpotsAvailable: 1})
+	server.AddLesson(fitforfree.Lesson{ID: "2", SpotsAvailable: 0})
+
+	sealer := getSealer()
+	handler := BookHandler(database.NewRepositories(db).Notis, server.Client(fitforfree.Config{}), sealer)
+
+	user := newSessionUser(sealer, 1, server.Token("123"))
+	db.Create(&database.Noti{UserID: user.ID, LessonID: "1"})
+
+	var response string
+	book := func(user database.User, lessonID string) {
+		handler(&bot.HandlePayload{
+			User:   user,
+			Update: newMockCallbackUpdate("book|" + lessonID),
+			Bot: mockSender{
+				OnSend: func(msg tgbotapi.Chattable) {
+					response = msg.(tgbotapi.MessageConfig).Text
+				},
+			},
+		}, lessonID)
+	}
+
+	book(user, "1")
+	if !strings.Contains(response, "geboekt") {
+		t.Errorf("Expected booked message, got %s", response)
+	}
+
+	if !server.Booked("123", "1") {
+		t.Error("Lesson is not booked upstream")
+	}
+
+	var count int64
+	db.Model(&database.Noti{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Error("Noti for booked lesson should be removed")
+	}
+
+	// The reason from the api is shown to the user
+	book(user, "2")
+	if !strings.Contains(response, "Les is vol") {
+		t.Errorf("Expected full message, got %s", response)
+	}
+
+	// Without a session nothing can be booked
+	book(database.User{ID: 2}, "1")
+	if !strings.Contains(response, "mislukt") {
+		t.Errorf("Expected failed message, got %s", response)
+	}
+}
+
+type callbackAnswerPayload struct {
+	name    string
+	handler func(*bot.HandlePayload, string)
+	user    database.User
+	data    string
+	answer  string
+}
+
+func TestCallbackAnswers(t *testing.T) {
+	databasetest.Run(t, testCallbackAnswers)
+}
+
+func testCallbackAnswers(t *testing.T, db *gorm.DB) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("123", "1234AB", fitforfree.User{})
+	server.AddMember("bot", "0000AA", fitforfree.User{})
+	server.AddLesson(fitforfree.Lesson{ID: "1", SpotsAvailable: 1})
+	server.AddLesson(fitforfree.Lesson{ID: "2", SpotsAvailable: 0})
+	server.AddVenue(fitforfree.Venue{ID: "1", Name: "Amsterdam Noord"})
+
+	sealer := getSealer()
+	client := server.Client(fitforfree.Config{})
+	session := client.NewSession("bot", "0000AA", nil)
+	repositories := database.NewRepositories(db)
+
+	user := newSessionUser(sealer, 1, server.Token("123"))
+	db.Create(&user)
+
+	book := BookHandler(repositories.Notis, client, sealer)
+	cancel := CancelBookingHandler(client, sealer)
+	setting := SettingHandler(repositories.Settings, session)
+	venue := VenueHandler(repositories.Users, session)
+
+	// Every callback is answered once, errors with a short toast
+	payloads := []callbackAnswerPayload{
+		{name: "book", handler: book, user: user, data: "1", answer: ""},
+		{name: "book full lesson", handler: book, user: user, data: "2", answer: "Boeken is mislukt"},
+		{name: "book without session", handler: book, user: database.User{ID: 2}, data: "1", answer: "Boeken is mislukt"},
+		{name: "cancel", handler: cancel, user: user, data: "1", answer: ""},
+		{name: "cancel without session", handler: cancel, user: database.User{ID: 2}, data: "1", answer: "Annuleren is mislukt"},
+		{name: "setting menu", handler: setting, user: user, data: "menu|language", answer: ""},
+		{name: "setting", handler: setting, user: user, data: "language|en", answer: ""},
+		{name: "invalid setting", handler: setting, user: user, data: "language|fr", answer: "Deze knop werkt niet meer"},
+		{name: "venue", handler: venue, user: user, data: "home|1", answer: ""},
+		{name: "invalid venue", handler: venue, user: user, data: "nearby|1", answer: "Deze knop werkt niet meer"},
+	}
+
+	for _, payload := range payloads {
+		answers := make([]tgbotapi.CallbackConfig, 0)
+		payload.handler(&bot.HandlePayload{
+			User:   payload.user,
+			Update: newMockCallbackUpdate(payload.data),
+			Bot: mockSender{
+				OnSend: func(tgbotapi.Chattable) {},
+				OnAnswer: func(answer tgbotapi.CallbackConfig) {
+					answers = append(answers, answer)
+				},
+			},
+		}, payload.data)
+
+		if len(answers) != 1 || answers[0].CallbackQueryID != "callback" || answers[0].Text != payload.answer {
+			t.Errorf("%s: expected one answer %q, got %+v", payload.name, payload.answer, answers)
+		}
+	}
+}
+
+func TestMyBookingsHandler(t *testing.T) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("123", "1234AB", fitforfree.User{})
+	now := uint(time.Now().Unix())
+	for i, id := range []string{"1", "2", "3"} {
+		server.AddLesson(fitforfree.Lesson{ID: id, VenueName: os.Getenv("VENUE"), StartTimestamp: now + uint(i+1)*3600, SpotsAvailable: 1})
+	}
+
+	sealer := getSealer()
+	client := server.Client(fitforfree.Config{})
+	token := server.Token("123")
+	client.BookLesson(context.Background(), "1", token)
+	client.BookLesson(context.Background(), "3", token)
+
+	handler := MyBookingsHandler(client, sealer)
+
+	update := newMockCommandUpdate("/mybookings", "")
+	update.Message.Chat = &tgbotapi.Chat{ID: 1}
+
+	sent := false
+	handler(&bot.HandlePayload{
+		User:   newSessionUser(sealer, 1, token),
+		Update: update,
+		Bot: mockSender{
+			OnSend: func(msg tgbotapi.Chattable) {
+				sent = true
+				message := msg.(tgbotapi.MessageConfig)
+				markup, ok := message.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+				if !ok {
+					t.Fatalf("Expected cancel buttons, got %s", message.Text)
+				}
+
+				if len(markup.InlineKeyboard) != 2 {
+					t.Errorf("Expected 2 booked lessons, got %d", len(markup.InlineKeyboard))
+				}
+
+				if *markup.InlineKeyboard[1][0].CallbackData != "cancel|3" {
+					t.Error("Cancel button has the wrong lesson")
+				}
+			},
+		},
+	}, []string{})
+
+	if !sent {
+		t.Error("No bookings sent")
+	}
+
+	// Pressing the button cancels upstream
+	CancelBookingHandler(client, sealer)(&bot.HandlePayload{
+		User:   newSessionUser(sealer, 1, token),
+		Update: newMockCallbackUpdate("cancel|3"),
+		Bot:    mockSender{OnSend: func(tgbotapi.Chattable) {}},
+	}, "3")
+
+	if server.Booked("123", "3") {
+		t.Error("Booking is not cancelled")
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	databasetest.Run(t, testLoginHandler)
+}
+
+func testLoginHandler(t *testing.T, db *gorm.DB) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("123", "1234AB", fitforfree.User{FirstName: "Test"})
+
+	sealer := getSealer()
+	handler := LoginHandler(database.NewRepositories(db).Users, server.Client(fitforfree.Config{}), sealer)
+
+	user := database.User{ID: 1}
+	db.Create(&user)
+
+	var response string
+	payload := bot.HandlePayload{
+		User:   user,
+		Update: newMockUpdate("1234AB"),
+		Bot: mockSender{
+			OnSend: func(msg tgbotapi.Chattable) {
+				response = msg.(tgbotapi.MessageConfig).Text
+			},
+		},
+	}
+
+	handler(&payload, &[]interface{}{nil, "123", "0000AA"})
+	if !strings.Contains(response, "klopt niet") {
+		t.Errorf("Expected wrong credentials message, got %s", response)
+	}
+
+	handler(&payload, &[]interface{}{nil, "123", "1234AB"})
+	if !strings.Contains(response, "Ingelogd als Test") {
+		t.Errorf("Expected logged in message, got %s", response)
+	}
+
+	stored := database.User{}
+	db.First(&stored, user.ID)
+
+	token, err := stored.Token(sealer)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if strings.Contains(string(stored.Session), token) {
+		t.Error("Session is stored in plain text")
+	}
+
+	// The stored session can be used upstream
+	if _, err := server.Client(fitforfree.Config{}).GetAllVenues(context.Background(), token); err != nil {
+		t.Errorf("Stored token is not valid: %+v", err)
+	}
+}
+
+func TestPostalCodeLoginHandler(t *testing.T) {
+	payload := bot.HandlePayload{
+		Bot: mockSender{OnSend: func(tgbotapi.Chattable) {}},
+	}
+
+	for input, expected := range map[string]interface{}{"1234 ab": "1234AB", "1234AB": "1234AB", "12345": nil, "AB1234": nil} {
+		payload.Update = newMockUpdate(input)
+		postalCode, continueConv := PostalCodeLoginHandler(&payload, nil)
+		if continueConv != (expected != nil) || postalCode != expected {
+			t.Errorf("Expected %v for %s, got %v", expected, input, postalCode)
+		}
+	}
+}
+
+func TestTypeNotiHandler(t *testing.T) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("bot", "0000AA", fitforfree.User{})
+
+	date, _ := time.Parse(times.DateLayout, "04-12-2030")
+	day := uint(date.Unix())
+	server.AddLesson(fitforfree.Lesson{ID: "1", VenueName: os.Getenv("VENUE"), StartTimestamp: day + 3600, ClassType: "group_lesson"})
+	server.AddLesson(fitforfree.Lesson{ID: "2", VenueName: os.Getenv("VENUE"), StartTimestamp: day + 7200, ClassType: "free_practise"})
+	server.AddLesson(fitforfree.Lesson{ID: "3", VenueName: os.Getenv("VENUE"), StartTimestamp: day + 60*60*24*2, ClassType: "group_lesson"})
+
+	session := server.Client(fitforfree.Config{}).NewSession("bot", "0000AA", nil)
+	handler := TypeNotiHandler(fitforfree.NewLessonCache(session, time.Hour, time.Minute))
+
+	payload := bot.HandlePayload{
+		Bot:    mockSender{OnSend: func(tgbotapi.Chattable) {}},
+		Update: newMockCallbackUpdate("group_lesson|mixed_lesson"),
+	}
+
+	for i := 0; i < 3; i++ {
+		lessons, continueConv := handler(&payload, &[]interface{}{nil, date})
+		if !continueConv {
+			t.Fatal("Should continue conv")
+		}
+
+		if l := lessons.([]fitforfree.Lesson); len(l) != 1 || l[0].ID != "1" {
+			t.Errorf("Expected only lesson 1, got %+v", l)
+		}
+	}
+
+	// Conversations picking the same day share the request
+	if requests := server.Requests("/v0/lessons/"); requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+}
+
+type formatEstimatePayload struct {
+	estimate forecast.Estimate
+	out      string
+}
+
+func TestFormatEstimate(t *testing.T) {
+	payloads := []formatEstimatePayload{
+		{
+			estimate: forecast.Estimate{Lessons: 4, Opened: 3, From: time.Hour * 2, To: time.Hour * 6},
+			out:      "Bij 3 van de 4 vergelijkbare volle lessen kwam er plek vrij (75%), meestal komt er 2-6 uur van tevoren plek vrij.",
+		},
+		{
+			estimate: forecast.Estimate{Lessons: 5, Opened: 1, From: time.Minute * 30, To: time.Minute * 30},
+			out:      "Bij 1 van de 5 vergelijkbare volle lessen kwam er plek vrij (20%), meestal komt er 30 minuten van tevoren plek vrij.",
+		},
+		{
+			estimate: forecast.Estimate{Lessons: 3, Opened: 3, From: time.Minute * 20, To: time.Hour * 3},
+			out:      "Bij 3 van de 3 vergelijkbare volle lessen kwam er plek vrij (100%), meestal komt er 20 minuten tot 3 uur van tevoren plek vrij.",
+		},
+		{
+			estimate: forecast.Estimate{Lessons: 3},
+			out:      "Bij de laatste 3 vergelijkbare volle lessen kwam er geen plek vrij.",
+		},
+	}
+
+	for _, payload := range payloads {
+		if out := formatEstimate("", payload.estimate); out != payload.out {
+			t.Errorf("Expected %q, got %q", payload.out, out)
+		}
+	}
+}
+
+func TestFormatAlerts(t *testing.T) {
+	alertedAt := time.Unix(1000, 0)
+	alerts := []database.Alert{
+		{LessonID: "1", LessonName: "Yoga", Spots: 1, Policy: "rotating", User: database.User{Name: "Anna"}, Booked: true, AlertedAt: alertedAt},
+		{LessonID: "1", LessonName: "Yoga", Spots: 1, Policy: "rotating", User: database.User{Name: "Bas"}, Position: 1, Delay: time.Minute, AlertedAt: alertedAt},
+		{LessonID: "1", LessonName: "Yoga", Spots: 1, Policy: "rotating", User: database.User{Name: "Bas"}, AlertedAt: alertedAt.Add(time.Hour)},
+	}
+
+	msg := formatAlerts(database.UserSettings{}, alerts)
+
+	if strings.Count(msg, "Yoga") != 2 {
+		t.Errorf("Expected a header for both times the lesson opened, got %q", msg)
+	}
+
+	for _, line := range []string{"1. Anna na 0s, geboekt\n", "2. Bas na 1m0s\n", "1. Bas na 0s\n", "1 plek(ken)", "(rotating)"} {
+		if !strings.Contains(msg, line) {
+			t.Errorf("Expected %q in %q", line, msg)
+		}
+	}
+}
+
+func TestVenuesHandler(t *testing.T) {
+	databasetest.Run(t, testVenuesHandler)
+}
+
+func testVenuesHandler(t *testing.T, db *gorm.DB) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("bot", "0000AA", fitforfree.User{})
+	server.AddVenue(fitforfree.Venue{ID: "1", Name: "Amsterdam Noord"})
+	server.AddVenue(fitforfree.Venue{ID: "2", Name: "Amsterdam Zuid"})
+	server.AddVenue(fitforfree.Venue{ID: "3", Name: "Rotterdam"})
+	session := server.Client(fitforfree.Config{}).NewSession("bot", "0000AA", nil)
+
+	user := database.User{ID: 1}
+	db.Create(&user)
+
+	update := newMockCommandUpdate("/venues", "amsterdam")
+	update.Message.Chat = &tgbotapi.Chat{ID: 1}
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	VenuesHandler(session)(&bot.HandlePayload{
+		User:   user,
+		Update: update,
+		Bot: mockSender{
+			OnSend: func(msg tgbotapi.Chattable) {
+				markup, _ = msg.(tgbotapi.MessageConfig).ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+			},
+		},
+	}, []string{"amsterdam"})
+
+	if len(markup.InlineKeyboard) != 2 || *markup.InlineKeyboard[1][0].CallbackData != "venue|home|2" {
+		t.Fatalf("Expected buttons for both Amsterdam venues, got %+v", markup.InlineKeyboard)
+	}
+
+	var response string
+	pick := func(data string) {
+		db.Preload("Settings").First(&user, 1)
+		VenueHandler(database.NewRepositories(db).Users, session)(&bot.HandlePayload{
+			User:   user,
+			Update: newMockCallbackUpdate("venue|" + data),
+			Bot: mockSender{
+				OnSend: func(msg tgbotapi.Chattable) {
+					response = msg.(tgbotapi.MessageConfig).Text
+				},
+			},
+		}, data)
+	}
+
+	pick("home|2")
+	pick("extra|3")
+
+	db.Preload("Settings").First(&user, 1)
+	if venues := user.Venues(); len(venues) != 2 || venues[0] != "2" || venues[1] != "3" {
+		t.Errorf("Expected home venue 2 and extra venue 3, got %v", venues)
+	}
+
+	if !strings.Contains(response, "Thuis sportschool: Amsterdam Zuid") || !strings.Contains(response, "Extra sportschool: Rotterdam") {
+		t.Errorf("Expected the venues by name, got %q", response)
+	}
+}
+
+func TestDateNotiHandlerDefaultClassType(t *testing.T) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("bot", "0000AA", fitforfree.User{})
+
+	user := database.User{ID: 1, Settings: database.UserSettings{ClassType: database.ClassTypeFree, Timezone: "UTC"}}
+	date, _ := time.Parse(times.DateLayout, "04-12-2030")
+	day := uint(date.Unix())
+	server.AddLesson(fitforfree.Lesson{ID: "1", StartTimestamp: day + 3600, ClassType: "group_lesson"})
+	server.AddLesson(fitforfree.Lesson{ID: "2", StartTimestamp: day + 7200, ClassType: "free_practise"})
+
+	session := server.Client(fitforfree.Config{}).NewSession("bot", "0000AA", nil)
+	handler := DateNotiHandler(fitforfree.NewLessonCache(session, time.Hour, time.Minute))
+
+	var response string
+	payload := bot.HandlePayload{
+		User: user,
+		Bot: mockSender{OnSend: func(msg tgbotapi.Chattable) {
+			response = msg.(tgbotapi.MessageConfig).Text
+		}},
+		Update: tgbotapi.Update{Message: &tgbotapi.Message{Text: "04-12-2030", Chat: &tgbotapi.Chat{ID: 1}}},
+	}
+
+	state := []interface{}{nil}
+	lessons, continueConv := handler(&payload, &state)
+	if !continueConv {
+		t.Fatalf("Should continue conv, got %q", response)
+	}
+
+	// The type question is skipped, the next step is picking the lesson
+	if l := lessons.([]fitforfree.Lesson); len(l) != 1 || l[0].ID != "2" {
+		t.Errorf("Expected only free lesson 2, got %+v", l)
+	}
+	if len(state) != 2 || !state[1].(time.Time).Equal(date) {
+		t.Errorf("Expected the date in the state, got %+v", state)
+	}
+	if !strings.Contains(response, "Welk les nummer") {
+		t.Errorf("Expected the lessons to pick from, got %q", response)
+	}
+}
+
+func TestSettingHandler(t *testing.T) {
+	databasetest.Run(t, testSettingHandler)
+}
+
+func testSettingHandler(t *testing.T, db *gorm.DB) {
+	server := fitforfreetest.NewServer()
+	defer server.Close()
+	server.AddMember("bot", "0000AA", fitforfree.User{})
+	server.AddVenue(fitforfree.Venue{ID: "1", Name: "Amsterdam Noord"})
+	session := server.Client(fitforfree.Config{}).NewSession("bot", "0000AA", nil)
+
+	user := database.User{ID: 1, Settings: database.UserSettings{HomeVenue: "1"}}
+	db.Create(&user)
+
+	var response tgbotapi.MessageConfig
+	handler := SettingHandler(database.NewRepositories(db).Settings, session)
+	pick := func(data string) {
+		db.Preload("Settings").First(&user, 1)
+		handler(&bot.HandlePayload{
+			User:   user,
+			Update: newMockCallbackUpdate("settings|" + data),
+			Bot: mockSender{
+				OnSend: func(msg tgbotapi.Chattable) {
+					response = msg.(tgbotapi.MessageConfig)
+				},
+			},
+		}, data)
+	}
+
+	pick("menu|quiet")
+	markup := response.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if len(markup.InlineKeyboard) != len(settingOptions["quiet"]) || *markup.InlineKeyboard[1][0].CallbackData != "settings|quiet|22-7" {
+		t.Errorf("Expected a button for every quiet hours option, got %+v", markup.InlineKeyboard)
+	}
+
+	pick("quiet|22-7")
+	pick("language|en")
+	pick("class|free")
+	pick("language|fr")
+
+	db.Preload("Settings").First(&user, 1)
+	settings := user.Settings
+	if settings.QuietFrom != 22 || settings.QuietUntil != 7 || settings.Language != "en" || settings.ClassType != database.ClassTypeFree {
+		t.Errorf("Expected the settings to be saved, got %+v", settings)
+	}
+
+	// The settings are shown in the new language
+	for _, line := range []string{"Your settings are changed.", "Quiet hours: 22:00 - 07:00", "Home gym: Amsterdam Noord", "Default lesson type: Free practice"} {
+		if !strings.Contains(response.Text, line) {
+			t.Errorf("Expected %q in %q", line, response.Text)
+		}
+	}
+
+	pick("class|ask")
+	db.Preload("Settings").First(&user, 1)
+	if user.Settings.ClassType != "" {
+		t.Errorf("Expected to be asked for the class type again, got %q", user.Settings.ClassType)
+	}
+}
+
+func TestSettingsTranslated(t *testing.T) {
+	for key, name := range settingNames {
+		if locale.T(locale.English, name) == name {
+			t.Errorf("Setting %s has no english name", key)
+		}
+
+		for _, option := range settingOptions[key] {
+			if key == "class" || key == "style" || option.value == "0-0" {
+				if locale.T(locale.English, option.label) == option.label {
+					t.Errorf("Option %s of setting %s has no english label", option.value, key)
+				}
+			}
+		}
+	}
+}
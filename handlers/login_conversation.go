@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/laytan/go-fff-notifications-bot/bot"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+)
+
+// postalCodeRegex matches dutch postal codes like 1234AB or 1234 ab
+var postalCodeRegex = regexp.MustCompile(`^[1-9][0-9]{3} ?[a-zA-Z]{2}$`)
+
+// StartLoginHandler asks for the member id of the user's fitforfree account
+func StartLoginHandler(p *bot.HandlePayload, _ *[]interface{}) (interface{}, bool) {
+	p.Respond(p.T("Wat is je FitForFree lidnummer? Je vindt het in de app of op je pas. (/stop om dit gesprek te stoppen)"))
+	return nil, true
+}
+
+// MemberIDLoginHandler validates the member id entered and asks for the postal code
+func MemberIDLoginHandler(p *bot.HandlePayload, _ *[]interface{}) (interface{}, bool) {
+	if p.Update.Message == nil || strings.TrimSpace(p.Update.Message.Text) == "" {
+		p.Respond(p.T("Vul aub je lidnummer in."))
+		return nil, false
+	}
+
+	p.Respond(p.T("Wat is de postcode waarmee je bij FitForFree bent ingeschreven?"))
+	return strings.TrimSpace(p.Update.Message.Text), true
+}
+
+// PostalCodeLoginHandler validates the postal code entered
+func PostalCodeLoginHandler(p *bot.HandlePayload, _ *[]interface{}) (interface{}, bool) {
+	if p.Update.Message == nil || !postalCodeRegex.MatchString(strings.TrimSpace(p.Update.Message.Text)) {
+		p.Respond(p.T("Vul een geldige postcode in, bijvoorbeeld 1234AB."))
+		return nil, false
+	}
+
+	postalCode := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(p.Update.Message.Text), " ", ""))
+	return postalCode, true
+}
+
+// LoginHandler logs in with the credentials from the conversation and stores the encrypted session on the user
+func LoginHandler(users database.UserRepository, client *fitforfree.Client, sealer *database.Sealer) bot.ConversationFinalizerFunc {
+	return func(p *bot.HandlePayload, s *[]interface{}) {
+		memberID := (*s)[1].(string)
+		postalCode := (*s)[2].(string)
+
+		session, err := client.Login(context.Background(), memberID, postalCode)
+		if err != nil {
+			if errors.Is(err, fitforfree.ErrUnauthorized) {
+				p.Respond(p.T("Je lidnummer of postcode klopt niet, probeer het opnieuw met /login."))
+				return
+			}
+
+			log.Printf("ERROR: Error logging in user %d to fitforfree, err: %+v", p.User.ID, err)
+			p.Respond(p.T("Inloggen bij FitForFree is mislukt, probeer het later opnieuw."))
+			return
+		}
+
+		user := p.User
+		if err := user.SetSession(sealer, *session); err != nil {
+			log.Printf("ERROR: Error encrypting session of user %d, err: %+v", p.User.ID, err)
+			p.Respond(p.T("Er ging iets fout bij het opslaan van je account, probeer het opnieuw."))
+			return
+		}
+
+		if err := users.SaveSession(context.Background(), user); err != nil {
+			log.Printf("ERROR: Error saving session of user %d, err: %+v", p.User.ID, err)
+			p.Respond(p.T("Er ging iets fout bij het opslaan van je account, probeer het opnieuw."))
+			return
+		}
+
+		p.Respond(fmt.Sprintf(p.T("Ingelogd als %s, je kunt nu lessen boeken."), strings.TrimSpace(session.FirstName+" "+session.SurName)))
+	}
+}
+
+// LogoutHandler removes the user's fitforfree session
+func LogoutHandler(users database.UserRepository) func(*bot.HandlePayload, []string) {
+	return func(p *bot.HandlePayload, _ []string) {
+		user := p.User
+		user.Session = nil
+		if err := users.SaveSession(context.Background(), user); err != nil {
+			log.Printf("ERROR: Error removing session of user %d, err: %+v", p.User.ID, err)
+			p.Respond(p.T("Er ging iets fout bij het uitloggen, probeer het opnieuw."))
+			return
+		}
+
+		p.Respond(p.T("Je FitForFree account is ontkoppeld."))
+	}
+}
@@ -1,93 +1,138 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/laytan/go-fff-notifications-bot/bot"
 	"github.com/laytan/go-fff-notifications-bot/database"
 	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/forecast"
 	"github.com/laytan/go-fff-notifications-bot/times"
-	"gorm.io/gorm"
 )
 
+// classTypes are the class types of lessons to pick by the type chosen in the conversation, or by the user's default class type
+var classTypes = map[string][]string{
+	"group_lesson|mixed_lesson": {"group_lesson", "mixed_lesson"},
+	"free_practise":             {"free_practise"},
+	database.ClassTypeGroup:     {"group_lesson", "mixed_lesson"},
+	database.ClassTypeFree:      {"free_practise"},
+}
+
 // StartNotiHandler asks for the date of the new notification
 func StartNotiHandler(p *bot.HandlePayload, _ *[]interface{}) (interface{}, bool) {
-	p.Respond("Hier gaan we, welke datum wil je sporten? (/stop om dit gesprek te stoppen)")
+	p.Respond(p.T("Hier gaan we, welke datum wil je sporten? (/stop om dit gesprek te stoppen)"))
 	return nil, true
 }
 
-// DateNotiHandler validates the date entered and asks for the type of lesson for the notification
-func DateNotiHandler(p *bot.HandlePayload, _ *[]interface{}) (interface{}, bool) {
-	date, err := times.FromInput(p.Update.Message.Text, times.DateLayout)
-	if err != nil {
-		p.Respond(fmt.Sprintf("Vul een geldige datum in, bijvoorbeeld %s.", times.DateLayout))
-		return nil, false
-	}
+// DateNotiHandler validates the date entered, in the user's timezone, and asks for the type of lesson for the notification
+// Users with a default class type are not asked, they get the lessons of that type right away
+func DateNotiHandler(cache *fitforfree.LessonCache) bot.ConversationHandlerFunc {
+	return func(p *bot.HandlePayload, s *[]interface{}) (interface{}, bool) {
+		date, err := times.FromInputIn(p.Update.Message.Text, times.DateLayout, p.User.Settings.Location())
+		if err != nil {
+			p.Respond(fmt.Sprintf(p.T("Vul een geldige datum in, bijvoorbeeld %s."), times.DateLayout))
+			return nil, false
+		}
 
-	msg := tgbotapi.NewMessage(p.Update.Message.Chat.ID, "Groepsles of vrije les?")
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Groepsles", "group_lesson|mixed_lesson"),
-			tgbotapi.NewInlineKeyboardButtonData("Vrij", "free_practise"),
-		),
-	)
-	p.Bot.Send(msg)
+		if types, ok := classTypes[p.User.Settings.ClassType]; ok {
+			lessons, err := dayLessons(cache, p.User, date, types)
+			if err != nil {
+				log.Printf("ERROR: Error getting lessons in DateNotiHandler, err: %+v", err)
+				p.Respond(p.T("Er ging iets fout bij het ophalen van de lessen, vul de datum opnieuw in."))
+				return nil, false
+			}
+
+			if len(lessons) == 0 {
+				p.Respond(p.T("Geen lessen op dat moment, vul een andere datum in."))
+				return nil, false
+			}
+
+			respondLessons(p, lessons)
+			// The type question is skipped, so the date is stored here and the conversation continues with the lesson
+			*s = append(*s, date)
+			return lessons, true
+		}
+
+		msg := tgbotapi.NewMessage(p.Update.Message.Chat.ID, p.T("Groepsles of vrije les?"))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(p.T("Groepsles"), "group_lesson|mixed_lesson"),
+				tgbotapi.NewInlineKeyboardButtonData(p.T("Vrij"), "free_practise"),
+			),
+		)
+		p.Bot.Send(msg)
 
-	return date, true
+		return date, true
+	}
 }
 
 // TypeNotiHandler validates the type entered and shows all lessons a notification can be added to asking for the number of the lesson they want to track
-func TypeNotiHandler(p *bot.HandlePayload, s *[]interface{}) (interface{}, bool) {
-	if p.Update.CallbackQuery == nil || !(p.Update.CallbackQuery.Data == "group_lesson|mixed_lesson" || p.Update.CallbackQuery.Data == "free_practise") {
-		p.Respond("Kies aub Groepsles of Vrij.")
-		return nil, false
+// The lessons are of all the user's venues and come from the schedule cache so users picking lessons on the same day share requests
+func TypeNotiHandler(cache *fitforfree.LessonCache) bot.ConversationHandlerFunc {
+	return func(p *bot.HandlePayload, s *[]interface{}) (interface{}, bool) {
+		if p.Update.CallbackQuery == nil || !(p.Update.CallbackQuery.Data == "group_lesson|mixed_lesson" || p.Update.CallbackQuery.Data == "free_practise") {
+			p.Respond(p.T("Kies aub Groepsles of Vrij."))
+			return nil, false
+		}
+
+		lessons, err := dayLessons(cache, p.User, (*s)[1].(time.Time), classTypes[p.Update.CallbackQuery.Data])
+		if err != nil {
+			log.Printf("ERROR: Error getting lessons in TypeNotiHandler, err: %+v", err)
+			p.Respond(p.T("Er ging iets fout bij het ophalen van de lessen, kies opnieuw Groepsles of Vrij."))
+			return nil, false
+		}
+
+		if len(lessons) == 0 {
+			p.Respond(p.T("Geen lessen op dat moment, vul een andere datum in."))
+			// Override the state so we get back to the DateNotiHandler
+			*s = []interface{}{nil}
+			return nil, false
+		}
+
+		respondLessons(p, lessons)
+		return lessons, true
 	}
-	classType := p.Update.CallbackQuery.Data
+}
 
-	selectedStamp := (*s)[1].(time.Time).Unix()
+// dayLessons returns the lessons of the class types at the user's venues on the day starting at date
+func dayLessons(cache *fitforfree.LessonCache, user database.User, date time.Time, types []string) ([]fitforfree.Lesson, error) {
+	selectedStamp := date.Unix()
 	end := selectedStamp + 60*60*24
-	lessons := fitforfree.GetLessons(uint(selectedStamp)-1, uint(end)+1, []string{os.Getenv("VENUE")}, os.Getenv("FIT_FOR_FREE_TOKEN"))
-	filteredTypes := fitforfree.Filter(lessons, func(lesson fitforfree.Lesson) bool {
-		if strings.Contains(classType, "|") {
-			types := strings.Split(classType, "|")
-			for _, t := range types {
-				if t == lesson.ClassType {
-					return true
-				}
+	lessons, err := cache.Schedule(context.Background(), uint(selectedStamp)-1, uint(end)+1, user.Venues())
+	if err != nil {
+		return nil, err
+	}
+
+	return fitforfree.Filter(lessons, func(lesson fitforfree.Lesson) bool {
+		for _, t := range types {
+			if t == lesson.ClassType {
+				return true
 			}
-		} else if classType == lesson.ClassType {
-			return true
 		}
 		return false
-	})
-
-	if len(filteredTypes) == 0 {
-		p.Respond("Geen lessen op dat moment, vul een andere datum in.")
-		// Override the state so we get back to the DateNotiHandler
-		*s = []interface{}{nil}
-		return nil, false
-	}
+	}), nil
+}
 
+// respondLessons asks for the number of the lesson to add a notification to
+func respondLessons(p *bot.HandlePayload, lessons []fitforfree.Lesson) {
 	msg := ""
-	for i, lesson := range filteredTypes {
-		msg += formatLesson(lesson, uint(i))
+	for i, lesson := range lessons {
+		msg += formatLesson(p.User.Settings, lesson, uint(i), len(p.User.Venues()) > 1)
 	}
 
-	p.Respond(fmt.Sprintf("Welk les nummer wil je in de gaten houden? Hier zijn ze allemaal: %s", msg))
-	return filteredTypes, true
+	p.Respond(fmt.Sprintf(p.T("Welk les nummer wil je in de gaten houden? Hier zijn ze allemaal: %s"), msg))
 }
 
 // ClassNotiHandler gets the lesson for the entered and validates it
 func ClassNotiHandler(p *bot.HandlePayload, s *[]interface{}) (interface{}, bool) {
 	num, err := strconv.Atoi(p.Update.Message.Text)
 	if err != nil {
-		p.Respond("Ongeldig nummer, probeer opnieuw.")
+		p.Respond(p.T("Ongeldig nummer, probeer opnieuw."))
 		return nil, false
 	}
 
@@ -95,37 +140,100 @@ func ClassNotiHandler(p *bot.HandlePayload, s *[]interface{}) (interface{}, bool
 
 	// Uint so minus doesn't work
 	if uint(num) >= uint(len(lessons)) {
-		p.Respond("Geen les met dat nummer gevonden, probeer opnieuw.")
+		p.Respond(p.T("Geen les met dat nummer gevonden, probeer opnieuw."))
 		return nil, false
 	}
 
+	msg := tgbotapi.NewMessage(p.Update.Message.Chat.ID, p.T("Wil je dat de les automatisch voor je geboekt wordt als er plek vrijkomt?"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.T("Ja, boek automatisch"), "auto_book"),
+			tgbotapi.NewInlineKeyboardButtonData(p.T("Nee, alleen een notificatie"), "notify"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.T("Nee, blijf me waarschuwen tot ik geboekt heb"), "watch"),
+		),
+	)
+	p.Bot.Send(msg)
+
 	return uint(num), true
 }
 
+// AutoBookNotiHandler validates whether the lesson should be booked automatically, which requires the user's own session
+// Instead the user can get one notification or keep watching the lesson until they book it
+func AutoBookNotiHandler(p *bot.HandlePayload, _ *[]interface{}) (interface{}, bool) {
+	if p.Update.CallbackQuery == nil {
+		p.Respond(p.T("Kies aub of de les automatisch geboekt moet worden."))
+		return nil, false
+	}
+
+	switch p.Update.CallbackQuery.Data {
+	case "notify":
+		return database.NotiOptions{}, true
+	case "watch":
+		return database.NotiOptions{Watch: true}, true
+	case "auto_book":
+		if !p.User.HasSession() {
+			p.Respond(p.T("Je hebt nog geen FitForFree account gekoppeld, je krijgt alleen een notificatie. Koppel je account met /login."))
+			return database.NotiOptions{}, true
+		}
+		return database.NotiOptions{AutoBook: true}, true
+	default:
+		p.Respond(p.T("Kies aub of de les automatisch geboekt moet worden."))
+		return nil, false
+	}
+}
+
 // NotiHandler adds a new noti based on the conversations state
-func NotiHandler(db *gorm.DB) bot.ConversationFinalizerFunc {
+func NotiHandler(notis database.NotiRepository, observations database.ObservationRepository) bot.ConversationFinalizerFunc {
 	return func(p *bot.HandlePayload, s *[]interface{}) {
 		num := (*s)[3].(uint)
 		lesson := (*s)[2].([]fitforfree.Lesson)[num]
+		options := (*s)[4].(database.NotiOptions)
 
 		if lesson.StartTimestamp < uint(time.Now().Unix()) {
-			p.Respond("Je kan alleen tijden in de toekomst toevoegen, probeer opnieuw")
+			p.Respond(p.T("Je kan alleen tijden in de toekomst toevoegen, probeer opnieuw"))
 			return
 		}
 
-		if err := database.CreateNoti(db, p.User, lesson); err != nil {
-			p.Respond("Er ging iets fout bij het toevoegen van de noti.")
+		if err := notis.Create(context.Background(), p.User, lesson, options); err != nil {
+			p.Respond(p.T("Er ging iets fout bij het toevoegen van de noti."))
 			log.Printf("ERROR: Error creating noti, error: %+v", err)
 			return
 		}
 
+		title := p.T("Notificatie aangezet voor les:")
+		switch {
+		case options.AutoBook:
+			title = p.T("Notificatie aangezet, de les wordt automatisch geboekt als er plek is:")
+		case options.Watch:
+			title = p.T("Notificatie aangezet, je krijgt bericht elke keer dat er weer plek vrijkomt tot je boekt of de les begint:")
+		}
+
 		p.Respond(
 			fmt.Sprintf(
 				`%s
-				%s`,
-				"Notificatie aangezet voor les:",
-				formatLesson(lesson, num),
+				%s%s`,
+				title,
+				formatLesson(p.User.Settings, lesson, num, len(p.User.Venues()) > 1),
+				estimateLesson(p.User.Settings.Language, observations, lesson),
 			),
 		)
 	}
 }
+
+// estimateLesson returns how likely a spot opens in the lesson based on similar lessons, empty without enough history
+func estimateLesson(language string, repository database.ObservationRepository, lesson fitforfree.Lesson) string {
+	observations, err := repository.ByActivity(context.Background(), lesson.Activity.Name, time.Now().Add(-forecast.History))
+	if err != nil {
+		log.Printf("ERROR: Error getting observations to estimate lesson %s, err: %+v", lesson.ID, err)
+		return ""
+	}
+
+	estimate := forecast.For(observations, time.Unix(int64(lesson.StartTimestamp), 0))
+	if !estimate.Enough() {
+		return ""
+	}
+
+	return "\n\n" + formatEstimate(language, estimate)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/laytan/go-fff-notifications-bot/bot"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/locale"
+)
+
+// settingOption is a value a user can pick for a setting, with the dutch label of its button
+type settingOption struct {
+	value string
+	label string
+}
+
+// settingOptions are the options of every setting, by the key used in the callback data {key}|{value}
+var settingOptions = map[string][]settingOption{
+	"language": {
+		{value: locale.Dutch, label: locale.Languages[locale.Dutch]},
+		{value: locale.English, label: locale.Languages[locale.English]},
+	},
+	"timezone": {
+		{value: "Europe/Amsterdam", label: "Europe/Amsterdam"},
+		{value: "Europe/London", label: "Europe/London"},
+		{value: "Europe/Lisbon", label: "Europe/Lisbon"},
+		{value: "Europe/Athens", label: "Europe/Athens"},
+		{value: "UTC", label: "UTC"},
+	},
+	"quiet": {
+		{value: "0-0", label: "Geen"},
+		{value: "22-7", label: "22:00 - 07:00"},
+		{value: "23-7", label: "23:00 - 07:00"},
+		{value: "23-8", label: "23:00 - 08:00"},
+		{value: "0-8", label: "00:00 - 08:00"},
+	},
+	"class": {
+		{value: "ask", label: "Altijd vragen"},
+		{value: database.ClassTypeGroup, label: "Groepsles"},
+		{value: database.ClassTypeFree, label: "Vrij"},
+	},
+	"style": {
+		{value: database.StyleFull, label: "Volledig"},
+		{value: database.StyleShort, label: "Kort"},
+		{value: database.StyleSilent, label: "Zonder geluid"},
+	},
+}
+
+// settingKeys are the keys of the settings in the order they are shown
+var settingKeys = []string{"language", "timezone", "quiet", "class", "style"}
+
+// settingNames are the dutch names of the settings on the buttons that show their options
+var settingNames = map[string]string{
+	"language": "Taal",
+	"timezone": "Tijdzone",
+	"quiet":    "Stille uren",
+	"class":    "Standaard lessoort",
+	"style":    "Meldingen",
+}
+
+// SettingsHandler shows the settings of the user with buttons to change them
+func SettingsHandler(session *fitforfree.Session) func(*bot.HandlePayload, []string) {
+	return func(p *bot.HandlePayload, _ []string) {
+		respondSettings(p, session, "")
+	}
+}
+
+// SettingHandler shows the options of a setting or changes it, data is menu|{key} or {key}|{value}
+func SettingHandler(repository database.SettingsRepository, session *fitforfree.Session) func(*bot.HandlePayload, string) {
+	return func(p *bot.HandlePayload, data string) {
+		parts := strings.SplitN(data, "|", 2)
+		if len(parts) != 2 {
+			log.Printf("ERROR: Invalid settings callback data %q", data)
+			p.Answer(p.T("Deze knop werkt niet meer"))
+			return
+		}
+
+		if parts[0] == "menu" {
+			p.Answer("")
+			respondSettingOptions(p, parts[1])
+			return
+		}
+
+		settings := p.User.Settings
+		settings.UserID = p.User.ID
+		if !applySetting(&settings, parts[0], parts[1]) {
+			log.Printf("ERROR: Invalid settings callback data %q", data)
+			p.Answer(p.T("Deze knop werkt niet meer"))
+			return
+		}
+
+		if err := repository.Save(context.Background(), settings); err != nil {
+			log.Printf("ERROR: Error saving settings of user %d, err: %+v", p.User.ID, err)
+			p.Answer(p.T("Opslaan is mislukt"))
+			p.Respond(p.T("Er ging iets fout bij het opslaan van je instellingen, probeer het opnieuw."))
+			return
+		}
+
+		// Respond in the new language right away
+		p.User.Settings = settings
+		p.Answer("")
+		respondSettings(p, session, p.T("Je instellingen zijn aangepast."))
+	}
+}
+
+// applySetting sets the setting with the key to the value, false when the value is not one of its options
+func applySetting(settings *database.UserSettings, key string, value string) bool {
+	valid := false
+	for _, option := range settingOptions[key] {
+		if option.value == value {
+			valid = true
+		}
+	}
+	if !valid {
+		return false
+	}
+
+	switch key {
+	case "language":
+		settings.Language = value
+	case "timezone":
+		settings.Timezone = value
+	case "quiet":
+		hours := strings.SplitN(value, "-", 2)
+		from, _ := strconv.Atoi(hours[0])
+		until, _ := strconv.Atoi(hours[1])
+		settings.QuietFrom = uint8(from)
+		settings.QuietUntil = uint8(until)
+	case "class":
+		settings.ClassType = value
+		if value == "ask" {
+			settings.ClassType = ""
+		}
+	case "style":
+		settings.NotificationStyle = value
+	default:
+		return false
+	}
+	return true
+}
+
+// respondSettings sends the settings of the user, after the title if there is one, with a button for every setting
+func respondSettings(p *bot.HandlePayload, session *fitforfree.Session, title string) {
+	venues, err := session.GetAllVenues(context.Background())
+	if err != nil {
+		// The home venue is shown by id instead
+		log.Printf("ERROR: Error getting venues in respondSettings, err: %+v", err)
+	}
+
+	msg := formatSettings(p.User, venues)
+	if title != "" {
+		msg = title + "\n" + msg
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(settingKeys))
+	for _, key := range settingKeys {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.T(settingNames[key]), fmt.Sprintf("settings|menu|%s", key)),
+		))
+	}
+
+	reply := tgbotapi.NewMessage(p.ChatID(), msg)
+	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	p.Bot.Send(reply)
+}
+
+// respondSettingOptions sends a button for every option of the setting with the key
+func respondSettingOptions(p *bot.HandlePayload, key string) {
+	options, ok := settingOptions[key]
+	if !ok {
+		log.Printf("ERROR: Invalid setting %q", key)
+		return
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(options))
+	for _, option := range options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.T(option.label), fmt.Sprintf("settings|%s|%s", key, option.value)),
+		))
+	}
+
+	reply := tgbotapi.NewMessage(p.ChatID(), fmt.Sprintf(p.T("Kies je %s:"), strings.ToLower(p.T(settingNames[key]))))
+	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	p.Bot.Send(reply)
+}
+
+// formatSettings formats the settings of the user, the home venue by name if it is in venues
+func formatSettings(user database.User, venues []fitforfree.Venue) string {
+	settings := user.Settings
+	t := func(text string) string {
+		return locale.T(settings.Language, text)
+	}
+
+	language := settings.Language
+	if language == "" {
+		language = locale.Dutch
+	}
+
+	timezone := settings.Timezone
+	if timezone == "" {
+		timezone = database.DefaultTimezone
+	}
+
+	quiet := t("geen")
+	if settings.HasQuietHours() {
+		quiet = fmt.Sprintf("%02d:00 - %02d:00", settings.QuietFrom, settings.QuietUntil)
+	}
+
+	return fmt.Sprintf(
+		t("Taal: %s\nTijdzone: %s\nThuis sportschool: %s (wijzig met /venues)\nStille uren: %s\nStandaard lessoort: %s\nMeldingen: %s"),
+		locale.Languages[language],
+		timezone,
+		venueName(venues, user.Venues()[0]),
+		quiet,
+		t(optionLabel("class", settings.ClassType, "ask")),
+		t(optionLabel("style", settings.NotificationStyle, database.StyleFull)),
+	)
+}
+
+// optionLabel returns the dutch label of the value of the setting with the key, the label of def when value is empty
+func optionLabel(key string, value string, def string) string {
+	if value == "" {
+		value = def
+	}
+
+	for _, option := range settingOptions[key] {
+		if option.value == value {
+			return option.label
+		}
+	}
+	return value
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/laytan/go-fff-notifications-bot/bot"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
+	"github.com/laytan/go-fff-notifications-bot/locale"
+)
+
+// maxVenueResults is the most venues a search shows, so the buttons fit in a message
+const maxVenueResults = 10
+
+// VenuesHandler shows the user's venues, or searches the venues by name with buttons to pick them as home or extra venue
+func VenuesHandler(session *fitforfree.Session) func(*bot.HandlePayload, []string) {
+	return func(p *bot.HandlePayload, args []string) {
+		venues, err := session.GetAllVenues(context.Background())
+		if err != nil {
+			log.Printf("ERROR: Error getting venues in VenuesHandler, err: %+v", err)
+			p.Respond(p.T("Er ging iets fout bij het ophalen van de sportscholen, probeer het opnieuw."))
+			return
+		}
+
+		if len(args) == 0 {
+			p.Respond(formatUserVenues(p.User, venues))
+			return
+		}
+
+		found := searchVenues(venues, strings.Join(args, " "))
+		if len(found) == 0 {
+			p.Respond(p.T("Geen sportscholen gevonden, probeer een andere zoekterm."))
+			return
+		}
+
+		msg := p.T("Kies je thuis sportschool of voeg een extra sportschool toe:")
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(found))
+		for i, venue := range found {
+			msg += fmt.Sprintf("\n%d. %s", i, venue.Name)
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf(p.T("Thuis %d"), i), fmt.Sprintf("venue|home|%s", venue.ID)),
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf(p.T("Extra %d"), i), fmt.Sprintf("venue|extra|%s", venue.ID)),
+			))
+		}
+
+		reply := tgbotapi.NewMessage(p.ChatID(), msg)
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		p.Bot.Send(reply)
+	}
+}
+
+// VenueHandler sets the venue as the user's home venue, or adds or removes it as extra venue, data is {home|extra}|{venue id}
+func VenueHandler(users database.UserRepository, session *fitforfree.Session) func(*bot.HandlePayload, string) {
+	return func(p *bot.HandlePayload, data string) {
+		parts := strings.SplitN(data, "|", 2)
+		if len(parts) != 2 || (parts[0] != "home" && parts[0] != "extra") {
+			log.Printf("ERROR: Invalid venue callback data %q", data)
+			p.Answer(p.T("Deze knop werkt niet meer"))
+			return
+		}
+
+		user := p.User
+		msg := p.T("Je thuis sportschool is aangepast.")
+		if parts[0] == "home" {
+			user.SetHomeVenue(parts[1])
+		} else if user.ToggleExtraVenue(parts[1]) {
+			msg = p.T("De sportschool is toegevoegd.")
+		} else {
+			msg = p.T("De sportschool is verwijderd.")
+		}
+
+		if err := users.SaveVenues(context.Background(), user); err != nil {
+			log.Printf("ERROR: Error saving venues of user %d, err: %+v", user.ID, err)
+			p.Answer(p.T("Opslaan is mislukt"))
+			p.Respond(p.T("Er ging iets fout bij het opslaan van de sportschool, probeer het opnieuw."))
+			return
+		}
+
+		p.Answer("")
+
+		venues, err := session.GetAllVenues(context.Background())
+		if err != nil {
+			log.Printf("ERROR: Error getting venues in VenueHandler, err: %+v", err)
+			p.Respond(msg)
+			return
+		}
+
+		p.Respond(fmt.Sprintf("%s\n%s", msg, formatUserVenues(user, venues)))
+	}
+}
+
+// searchVenues returns at most maxVenueResults venues whose name contains the query, ignoring case
+func searchVenues(venues []fitforfree.Venue, query string) []fitforfree.Venue {
+	query = strings.ToLower(query)
+
+	found := make([]fitforfree.Venue, 0)
+	for _, venue := range venues {
+		if strings.Contains(strings.ToLower(venue.Name), query) {
+			found = append(found, venue)
+			if len(found) == maxVenueResults {
+				break
+			}
+		}
+	}
+	return found
+}
+
+// formatUserVenues formats the venues of the user by name, venues that don't exist anymore show their id
+func formatUserVenues(user database.User, venues []fitforfree.Venue) string {
+	userVenues := user.Venues()
+	msg := fmt.Sprintf(locale.T(user.Settings.Language, "Thuis sportschool: %s"), venueName(venues, userVenues[0]))
+	for _, venue := range userVenues[1:] {
+		msg += fmt.Sprintf(locale.T(user.Settings.Language, "\nExtra sportschool: %s"), venueName(venues, venue))
+	}
+	msg += locale.T(user.Settings.Language, "\nZoek een sportschool om te kiezen met /venues {zoekterm}")
+
+	return msg
+}
+
+// venueName returns the name of the venue with the id, the id when it does not exist anymore
+func venueName(venues []fitforfree.Venue, id string) string {
+	for _, venue := range venues {
+		if venue.ID == id {
+			return venue.Name
+		}
+	}
+	return id
+}
@@ -0,0 +1,237 @@
+package locale
+
+// english are the english translations of the dutch texts
+var english = map[string]string{
+	// bot
+	"Gestopt": "Stopped",
+	"Boek nu": "Book now",
+
+	// help
+	"Te gebruiken commandos:": "Commands you can use:",
+	"- /noti: Start een gesprek om een nieuwe notificatie toe te voegen":           "- /noti: Start a conversation to add a new notification",
+	"- /notifications: Verkrijg een lijst met alle ingestelde notificaties":        "- /notifications: Get a list of all your notifications",
+	"- /clear: Verwijder al je notificaties":                                       "- /clear: Remove all your notifications",
+	"- /remove {nummer}: Verwijder de notificatie met het gegeven nummer":          "- /remove {number}: Remove the notification with the given number",
+	"- /mybookings: Bekijk en annuleer je geboekte lessen":                         "- /mybookings: View and cancel your booked lessons",
+	"- /login: Koppel je FitForFree account om lessen te boeken":                   "- /login: Link your FitForFree account to book lessons",
+	"- /logout: Ontkoppel je FitForFree account":                                   "- /logout: Unlink your FitForFree account",
+	"- /venues {zoekterm}: Bekijk je sportscholen of zoek er een om toe te voegen": "- /venues {search}: View your gyms or search one to add",
+	"- /settings: Bekijk en wijzig je instellingen":                                "- /settings: View and change your settings",
+
+	// notis
+	"Er ging iets fout, probeer het opnieuw": "Something went wrong, please try again",
+	"Geen notificaties gevonden":             "No notifications found",
+	"Geen notificaties gevonden.":            "No notifications found.",
+	"Stuur het nummer van de notificatie die verwijdert moet worden mee, zoals: /remove 1": "Send the number of the notification to remove, like: /remove 1",
+	"Nummer is niet goed ingevuld":                                                       "The number is not valid",
+	"Er bestaat geen notificatie met dat nummer":                                         "There is no notification with that number",
+	"Er ging iets fout bij het ophalen van de notificatie, probeer het opnieuw.":         "Something went wrong getting the notification, please try again.",
+	"Je kunt deze notificatie niet verwijderen omdat deze door iemand anders is gemaakt": "You can't remove this notification because someone else made it",
+	"Er ging iets fout bij het verwijderen van de notificatie, probeer het opnieuw.":     "Something went wrong removing the notification, please try again.",
+	"Notificatie verwijderd":                                                             "Notification removed",
+	"Er ging iets fout bij het verwijderen van de notificaties, probeer het opnieuw.":    "Something went wrong removing the notifications, please try again.",
+	"Notificaties verwijderd":                                                            "Notifications removed",
+
+	// booking errors
+	"je hebt nog geen FitForFree account gekoppeld, dat kan met /login": "you haven't linked a FitForFree account yet, you can with /login",
+	"je FitForFree sessie is verlopen, log opnieuw in met /login":       "your FitForFree session expired, log in again with /login",
+	"FitForFree heeft op dit moment problemen":                          "FitForFree is having problems right now",
+	"FitForFree is niet bereikbaar":                                     "FitForFree can't be reached",
+	"er ging iets fout":                                                 "something went wrong",
+
+	// formats
+	`
+		Nummer: %d
+		Activiteit: %s
+		Start: %s
+		Eind: %s`: `
+		Number: %d
+		Activity: %s
+		Start: %s
+		End: %s`,
+	`
+		Locatie: %s`: `
+		Location: %s`,
+	`
+		Nummer: %d
+		Activiteit: %s
+		Datum: %s
+		Start: %s
+		Eind: %s`: `
+		Number: %d
+		Activity: %s
+		Date: %s
+		Start: %s
+		End: %s`,
+	`
+		Nummer: %d
+		Soort: %s
+		Datum: %s
+		Start: %s
+		Eind: %s
+		Gemaakt: %s
+	`: `
+		Number: %d
+		Kind: %s
+		Date: %s
+		Start: %s
+		End: %s
+		Created: %s
+	`,
+	"Naam: %s": "Name: %s",
+	"Bij de laatste %d vergelijkbare volle lessen kwam er geen plek vrij.":                                              "No spot opened in the last %d similar full lessons.",
+	"Bij %d van de %d vergelijkbare volle lessen kwam er plek vrij (%.0f%%), meestal komt er %s van tevoren plek vrij.": "A spot opened in %d of the %d similar full lessons (%.0f%%), usually a spot opens %s before.",
+	"%d uur":                "%d hours",
+	"%d-%d uur":             "%d-%d hours",
+	"%d minuten":            "%d minutes",
+	"%d-%d minuten":         "%d-%d minutes",
+	"%d minuten tot %d uur": "%d minutes to %d hours",
+	"automatisch boeken":    "book automatically",
+	"blijven volgen":        "keep watching",
+	"eenmalige notificatie": "one notification",
+
+	// noti conversation
+	"Hier gaan we, welke datum wil je sporten? (/stop om dit gesprek te stoppen)": "Here we go, on what date do you want to work out? (/stop to stop this conversation)",
+	"Vul een geldige datum in, bijvoorbeeld %s.":                                  "Enter a valid date, for example %s.",
+	"Er ging iets fout bij het ophalen van de lessen, vul de datum opnieuw in.":   "Something went wrong getting the lessons, enter the date again.",
+	"Geen lessen op dat moment, vul een andere datum in.":                         "No lessons at that time, enter another date.",
+	"Groepsles of vrije les?":                                                     "Group lesson or free practice?",
+	"Groepsles":                                                                   "Group lesson",
+	"Vrij":                                                                        "Free practice",
+	"Kies aub Groepsles of Vrij.":                                                 "Please choose Group lesson or Free practice.",
+	"Er ging iets fout bij het ophalen van de lessen, kies opnieuw Groepsles of Vrij.": "Something went wrong getting the lessons, choose Group lesson or Free practice again.",
+	"Welk les nummer wil je in de gaten houden? Hier zijn ze allemaal: %s":             "Which lesson number do you want to watch? Here they all are: %s",
+	"Ongeldig nummer, probeer opnieuw.":                                                "Invalid number, please try again.",
+	"Geen les met dat nummer gevonden, probeer opnieuw.":                               "No lesson found with that number, please try again.",
+	"Wil je dat de les automatisch voor je geboekt wordt als er plek vrijkomt?":        "Do you want the lesson to be booked for you automatically when a spot opens?",
+	"Ja, boek automatisch":                                "Yes, book automatically",
+	"Nee, alleen een notificatie":                         "No, just a notification",
+	"Nee, blijf me waarschuwen tot ik geboekt heb":        "No, keep telling me until I booked",
+	"Kies aub of de les automatisch geboekt moet worden.": "Please choose whether the lesson should be booked automatically.",
+	"Je hebt nog geen FitForFree account gekoppeld, je krijgt alleen een notificatie. Koppel je account met /login.": "You haven't linked a FitForFree account yet, you only get a notification. Link your account with /login.",
+	"Je kan alleen tijden in de toekomst toevoegen, probeer opnieuw":                                                 "You can only add times in the future, please try again",
+	"Er ging iets fout bij het toevoegen van de noti.":                                                               "Something went wrong adding the notification.",
+	"Notificatie aangezet voor les:":                                                                                 "Notification turned on for lesson:",
+	"Notificatie aangezet, de les wordt automatisch geboekt als er plek is:":                                         "Notification turned on, the lesson is booked automatically when there is a spot:",
+	"Notificatie aangezet, je krijgt bericht elke keer dat er weer plek vrijkomt tot je boekt of de les begint:":     "Notification turned on, you get a message every time a spot opens until you book or the lesson starts:",
+
+	// bookings
+	"Je boekingen kunnen niet opgehaald worden, %s.": "Your bookings can't be retrieved, %s.",
+	"Je hebt geen lessen geboekt.":                   "You haven't booked any lessons.",
+	"Je geboekte lessen:":                            "Your booked lessons:",
+	"Annuleer %d":                                    "Cancel %d",
+	"Boeken is mislukt, %s.":                         "Booking failed, %s.",
+	"De les is geboekt!":                             "The lesson is booked!",
+	"Annuleren is mislukt, %s.":                      "Cancelling failed, %s.",
+	"De les is geannuleerd.":                         "The lesson is cancelled.",
+	"Boeken is mislukt":                              "Booking failed",
+	"Annuleren is mislukt":                           "Cancelling failed",
+
+	// callback toasts
+	"Deze knop werkt niet meer": "This button doesn't work anymore",
+	"Opslaan is mislukt":        "Saving failed",
+
+	// login
+	"Wat is je FitForFree lidnummer? Je vindt het in de app of op je pas. (/stop om dit gesprek te stoppen)": "What is your FitForFree member number? You can find it in the app or on your card. (/stop to stop this conversation)",
+	"Vul aub je lidnummer in.": "Please enter your member number.",
+	"Wat is de postcode waarmee je bij FitForFree bent ingeschreven?":        "What is the postal code you registered with at FitForFree?",
+	"Vul een geldige postcode in, bijvoorbeeld 1234AB.":                      "Enter a valid postal code, for example 1234AB.",
+	"Je lidnummer of postcode klopt niet, probeer het opnieuw met /login.":   "Your member number or postal code is wrong, try again with /login.",
+	"Inloggen bij FitForFree is mislukt, probeer het later opnieuw.":         "Logging in to FitForFree failed, please try again later.",
+	"Er ging iets fout bij het opslaan van je account, probeer het opnieuw.": "Something went wrong saving your account, please try again.",
+	"Ingelogd als %s, je kunt nu lessen boeken.":                             "Logged in as %s, you can book lessons now.",
+	"Er ging iets fout bij het uitloggen, probeer het opnieuw.":              "Something went wrong logging out, please try again.",
+	"Je FitForFree account is ontkoppeld.":                                   "Your FitForFree account is unlinked.",
+
+	// venues
+	"Er ging iets fout bij het ophalen van de sportscholen, probeer het opnieuw.": "Something went wrong getting the gyms, please try again.",
+	"Geen sportscholen gevonden, probeer een andere zoekterm.":                    "No gyms found, try another search.",
+	"Kies je thuis sportschool of voeg een extra sportschool toe:":                "Choose your home gym or add an extra gym:",
+	"Thuis %d":                           "Home %d",
+	"Extra %d":                           "Extra %d",
+	"Je thuis sportschool is aangepast.": "Your home gym is changed.",
+	"De sportschool is toegevoegd.":      "The gym is added.",
+	"De sportschool is verwijderd.":      "The gym is removed.",
+	"Er ging iets fout bij het opslaan van de sportschool, probeer het opnieuw.": "Something went wrong saving the gym, please try again.",
+	"Thuis sportschool: %s":   "Home gym: %s",
+	"\nExtra sportschool: %s": "\nExtra gym: %s",
+	"\nZoek een sportschool om te kiezen met /venues {zoekterm}": "\nSearch a gym to pick with /venues {search}",
+
+	// alerts
+	"Dit commando is alleen voor admins.":             "This command is for admins only.",
+	"Er is het afgelopen etmaal niemand gealarmeerd.": "Nobody was alerted in the last day.",
+	"\n%s %s %s, %d plek(ken) om %s (%s):\n":          "\n%s %s %s, %d spot(s) at %s (%s):\n",
+	"%d. %s na %s":                                    "%d. %s after %s",
+	", geboekt":                                       ", booked",
+
+	// backup
+	"Back-ups kunnen alleen van een sqlite database gemaakt worden, gebruik pg_dump voor PostgreSQL.": "Backups can only be made of a sqlite database, use pg_dump for PostgreSQL.",
+	"Er ging iets fout bij het maken van de back-up, probeer het opnieuw.":                            "Something went wrong making the backup, please try again.",
+	"De back-up is gemaakt maar kon niet verstuurd worden, hij staat op de server.":                   "The backup is made but could not be sent, it is on the server.",
+
+	// settings
+	"Er ging iets fout bij het opslaan van je instellingen, probeer het opnieuw.": "Something went wrong saving your settings, please try again.",
+	"Je instellingen zijn aangepast.":                                             "Your settings are changed.",
+	"Kies je %s:":                                                                 "Choose your %s:",
+	"geen":                                                                        "none",
+	"Taal: %s\nTijdzone: %s\nThuis sportschool: %s (wijzig met /venues)\nStille uren: %s\nStandaard lessoort: %s\nMeldingen: %s": "Language: %s\nTimezone: %s\nHome gym: %s (change with /venues)\nQuiet hours: %s\nDefault lesson type: %s\nNotifications: %s",
+	"Taal":               "Language",
+	"Tijdzone":           "Timezone",
+	"Stille uren":        "Quiet hours",
+	"Standaard lessoort": "Default lesson type",
+	"Meldingen":          "Notifications",
+	"Geen":               "None",
+	"Altijd vragen":      "Always ask",
+	"Volledig":           "Full",
+	"Kort":               "Short",
+	"Zonder geluid":      "Without sound",
+
+	// checker messages
+	"Er was plek vrij, de les is voor je geboekt!":                                                     "There was a spot, the lesson is booked for you!",
+	"Snel er is plek vrij! Automatisch boeken is mislukt: %s.":                                         "Quick, a spot opened! Booking automatically failed: %s.",
+	"Snel er is plek vrij! Anderen waren eerder aan de beurt, dus de les is niet automatisch geboekt.": "Quick, a spot opened! Others were first in line, so the lesson was not booked automatically.",
+	"Snel er is plek vrij!": "Quick, a spot opened!",
+	`
+		%s
+
+		Les: %s
+		Datum: %s
+		Start: %s
+		Eind: %s
+		`: `
+		%s
+
+		Lesson: %s
+		Date: %s
+		Start: %s
+		End: %s
+		`,
+	"Je krijgt weer bericht als de les vol raakt en er opnieuw plek vrijkomt, verwijder de notificatie als je niet meer wilt.": "You get a message again when the lesson fills up and a spot opens again, remove the notification if you don't want that anymore.",
+	"Je les is geannuleerd, de notificatie is verwijderd.":                                                                     "Your lesson is cancelled, the notification is removed.",
+	"Je les staat niet meer in het rooster.":                                                                                   "Your lesson is not in the schedule anymore.",
+	"Je les is gewijzigd.":                                                                                                     "Your lesson changed.",
+	`
+		%s
+
+		Les: %s
+		Datum: %s
+		Start: %s
+		`: `
+		%s
+
+		Lesson: %s
+		Date: %s
+		Start: %s
+		`,
+	`Nieuwe tijd: %s %s - %s
+		`: `New time: %s %s - %s
+		`,
+	`Nieuwe instructeur: %s
+		`: `New instructor: %s
+		`,
+	`Nieuwe zaal: %s
+		`: `New room: %s
+		`,
+	"Je les is begonnen en er is geen plek vrijgekomen, de notificatie is verwijderd.": "Your lesson started and no spot opened, the notification is removed.",
+	"Je les is begonnen, de notificatie is verwijderd.":                                "Your lesson started, the notification is removed.",
+}
@@ -0,0 +1,24 @@
+// Package locale translates the messages of the bot, the dutch text of a message is the key of its translations
+package locale
+
+const (
+	// Dutch is the language of users that did not pick one, messages are written in it
+	Dutch   = "nl"
+	English = "en"
+)
+
+// Languages are the languages users can pick, by their name in that language
+var Languages = map[string]string{
+	Dutch:   "Nederlands",
+	English: "English",
+}
+
+// T returns the dutch text in the language, the text itself for dutch or when it has no translation
+func T(language string, text string) string {
+	if language == English {
+		if translated, ok := english[text]; ok {
+			return translated
+		}
+	}
+	return text
+}
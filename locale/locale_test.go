@@ -0,0 +1,118 @@
+package locale
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	if T(English, "Gestopt") != "Stopped" {
+		t.Errorf("Expected the english translation, got %q", T(English, "Gestopt"))
+	}
+
+	if T(Dutch, "Gestopt") != "Gestopt" || T("", "Gestopt") != "Gestopt" {
+		t.Error("Expected dutch to be returned as is")
+	}
+
+	if T(English, "Geen vertaling") != "Geen vertaling" {
+		t.Error("Expected text without translation to be returned as is")
+	}
+}
+
+// TestTranslations checks that every text translated with T in the program has an english translation with the same verbs
+func TestTranslations(t *testing.T) {
+	texts := make(map[string]string)
+	err := filepath.Walk("..", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 || !isT(call.Fun) {
+				return true
+			}
+
+			// The text is the last argument of T, p.T and the t helpers
+			lit, ok := call.Args[len(call.Args)-1].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+
+			text, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Errorf("Can't read text at %s: %v", fset.Position(lit.Pos()), err)
+				return true
+			}
+			texts[text] = fset.Position(lit.Pos()).String()
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(texts) == 0 {
+		t.Fatal("Expected to find texts to translate")
+	}
+
+	for text, position := range texts {
+		translated, ok := english[text]
+		if !ok {
+			t.Errorf("No english translation of %q at %s", text, position)
+			continue
+		}
+
+		if verbs(text) != verbs(translated) {
+			t.Errorf("The translation of %q at %s has other verbs: %q", text, position, translated)
+		}
+	}
+}
+
+// isT returns if the function called is T, a method T or a helper t
+func isT(fun ast.Expr) bool {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name == "T" || f.Name == "t"
+	case *ast.SelectorExpr:
+		return f.Sel.Name == "T"
+	}
+	return false
+}
+
+// verbs returns the formatting verbs in the text in order
+func verbs(text string) string {
+	found := ""
+	for i := 0; i < len(text)-1; i++ {
+		if text[i] != '%' {
+			continue
+		}
+
+		// Skip flags and precision like %.0f or %02d
+		j := i + 1
+		for j < len(text) && strings.ContainsRune(".0123456789", rune(text[j])) {
+			j++
+		}
+		if j < len(text) {
+			found += text[i : j+1]
+		}
+		i = j
+	}
+	return found
+}
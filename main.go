@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -13,13 +16,35 @@ import (
 	"github.com/laytan/go-fff-notifications-bot/bot"
 	"github.com/laytan/go-fff-notifications-bot/checker"
 	"github.com/laytan/go-fff-notifications-bot/database"
+	"github.com/laytan/go-fff-notifications-bot/fitforfree"
 	"github.com/laytan/go-fff-notifications-bot/handlers"
+	"github.com/laytan/go-fff-notifications-bot/locale"
 	"github.com/laytan/go-fff-notifications-bot/logs"
 	"github.com/laytan/go-fff-notifications-bot/middleware"
 	"github.com/laytan/go-fff-notifications-bot/times"
+	"gorm.io/gorm"
 )
 
+// databasePath is the sqlite file the bot keeps its data in when DATABASE_URL is not set
+const databasePath = "database/database.sqlite"
+
 func main() {
+	// Manage the schema and backups without starting the bot
+	commands := map[string]func([]string) error{
+		"migrate": migrate,
+		"backup":  backup,
+		"restore": restore,
+	}
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	if err := godotenv.Load(); err != nil {
 		panic(err)
 	}
@@ -30,14 +55,72 @@ func main() {
 
 	log.Println("Starting program")
 
-	// Get database conn
-	db := database.New("database/database.sqlite", logs.NewDatabaseLogger(logFile))
+	// Get database conn, migrated to the latest migration
+	driver, dsn, err := databaseDSN()
+	if err != nil {
+		log.Panicf("ERROR: Invalid DATABASE_DRIVER environment variable, err: %+v", err)
+	}
+	db := database.New(driver, dsn, logs.NewDatabaseLogger(logFile))
+	repositories := database.NewRepositories(db)
+	backups := newBackups(db)
+
+	// Session for the fitforfree api, the token is kept next to the database so restarts don't need to log in
+	limiter := newRateLimiter()
+	log.Printf("Rate limiting fitforfree requests to %s", limiter)
+	client := fitforfree.NewClient(fitforfree.Config{
+		RateLimiter: limiter,
+		// Record responses to fixture files, or replay them to run without the fitforfree api
+		RecordDir: os.Getenv("FIT_FOR_FREE_RECORD_DIR"),
+		ReplayDir: os.Getenv("FIT_FOR_FREE_OFFLINE_DIR"),
+	})
+	if dir := os.Getenv("FIT_FOR_FREE_OFFLINE_DIR"); dir != "" {
+		log.Printf("Running offline, fitforfree responses are replayed from %s", dir)
+	} else if dir := os.Getenv("FIT_FOR_FREE_RECORD_DIR"); dir != "" {
+		log.Printf("Recording fitforfree responses to %s", dir)
+	}
+	session := client.NewSession(
+		os.Getenv("FIT_FOR_FREE_MEMBER_ID"),
+		os.Getenv("FIT_FOR_FREE_POSTAL_CODE"),
+		fitforfree.FileTokenStore("database/token"),
+	)
+
+	// Login errors are queued until the bot is started to send them to the admin
+	loginErrors := make(chan error, 10)
+	session.OnLoginError = func(err error) {
+		log.Printf("ERROR: Can't log in to fitforfree, err: %+v", err)
+		select {
+		case loginErrors <- err:
+		default:
+			// The admin already has enough errors to read
+		}
+	}
+
+	// Lessons are cached so the checker and conversations share requests
+	cache := fitforfree.NewLessonCache(session, time.Minute*30, time.Minute)
+
+	// Keep the availability of every lesson we see to learn when spots open
+	cache.OnFetch = func(lessons []fitforfree.Lesson, fetchedAt time.Time) {
+		if err := repositories.Observations.Record(context.Background(), lessons, fetchedAt); err != nil {
+			log.Printf("ERROR: Error recording lesson observations, err: %+v", err)
+		}
+	}
+
+	// Users' fitforfree sessions are encrypted with this key in the database
+	sessionKey, err := base64.StdEncoding.DecodeString(os.Getenv("SESSION_KEY"))
+	if err != nil {
+		log.Panicf("ERROR: SESSION_KEY environment variable is not valid base64: %+v", err)
+	}
+
+	sealer, err := database.NewSealer(sessionKey)
+	if err != nil {
+		log.Panicf("ERROR: SESSION_KEY environment variable is not a valid key: %+v", err)
+	}
 
 	// middlewares are ran on every chat update
 	middleware := []bot.Middleware{
 		{
 			IsSync:  true,
-			Handler: middleware.AssureUserExists(db),
+			Handler: middleware.AssureUserExists(repositories.Users),
 		},
 		{
 			IsSync:  false,
@@ -53,15 +136,56 @@ func main() {
 		},
 		&bot.CommandHandler{
 			Command: []string{"notificaties", "notifications"},
-			Handler: handlers.ListNotisHandler(db),
+			Handler: handlers.ListNotisHandler(repositories.Notis),
 		},
 		&bot.CommandHandler{
 			Command: []string{"verwijder", "remove"},
-			Handler: handlers.RemoveHandler(db),
+			Handler: handlers.RemoveHandler(repositories.Notis),
 		},
 		&bot.CommandHandler{
 			Command: []string{"clear"},
-			Handler: handlers.ClearHandler(db),
+			Handler: handlers.ClearHandler(repositories.Notis),
+		},
+		&bot.CommandHandler{
+			Command: []string{"logout"},
+			Handler: handlers.LogoutHandler(repositories.Users),
+		},
+		&bot.CommandHandler{
+			Command: []string{"venues", "sportscholen"},
+			Handler: handlers.VenuesHandler(session),
+		},
+		&bot.CommandHandler{
+			Command: []string{"settings", "instellingen"},
+			Handler: handlers.SettingsHandler(session),
+		},
+		&bot.CommandHandler{
+			Command: []string{"alerts"},
+			Handler: handlers.AlertsHandler(repositories.Alerts),
+		},
+		&bot.CommandHandler{
+			Command: []string{"backup"},
+			Handler: handlers.BackupHandler(backups),
+		},
+		&bot.CommandHandler{
+			Command: []string{"mybookings", "boekingen"},
+			Handler: handlers.MyBookingsHandler(client, sealer),
+		},
+		// Callbacks are matched before conversations so buttons work while in a conversation
+		&bot.CallbackHandler{
+			Prefix:  "book",
+			Handler: handlers.BookHandler(repositories.Notis, client, sealer),
+		},
+		&bot.CallbackHandler{
+			Prefix:  "cancel",
+			Handler: handlers.CancelBookingHandler(client, sealer),
+		},
+		&bot.CallbackHandler{
+			Prefix:  "venue",
+			Handler: handlers.VenueHandler(repositories.Users, session),
+		},
+		&bot.CallbackHandler{
+			Prefix:  "settings",
+			Handler: handlers.SettingHandler(repositories.Settings, session),
 		},
 		bot.NewConversationHandler(
 			[]string{"noti"},
@@ -69,53 +193,76 @@ func main() {
 				// Ask for date
 				handlers.StartNotiHandler,
 				// Ask for group or free
-				handlers.DateNotiHandler,
+				handlers.DateNotiHandler(cache),
 				// Show lessons on that day and ask for choise
-				handlers.TypeNotiHandler,
-				// Get specific class
+				handlers.TypeNotiHandler(cache),
+				// Get specific class and ask to book automatically
 				handlers.ClassNotiHandler,
+				// Get whether to book automatically
+				handlers.AutoBookNotiHandler,
 			},
-			handlers.NotiHandler(db),
+			handlers.NotiHandler(repositories.Notis, repositories.Observations),
+		),
+		bot.NewConversationHandler(
+			[]string{"login"},
+			[]bot.ConversationHandlerFunc{
+				// Ask for member id
+				handlers.StartLoginHandler,
+				// Ask for postal code
+				handlers.MemberIDLoginHandler,
+				// Validate postal code
+				handlers.PostalCodeLoginHandler,
+			},
+			handlers.LoginHandler(repositories.Users, client, sealer),
 		),
 	}
 
 	// start bot with our middlewares and handlers
-	bot := bot.Start(middleware, handlers)
+	telegram := bot.Start(middleware, handlers)
 
-	// Setup checker
-	checkerT := time.NewTicker(time.Second * 100)
-	shouldNotify := make(chan database.Noti)
-	// Wait for checker in other goroutine
+	// Let the admin know when we can't log in to fitforfree anymore, also about errors from before the bot started
 	go func() {
-		for {
-			<-checkerT.C
-			// Initiate the check
-			checker.AvailabilityCheck(db, []string{os.Getenv("VENUE")}, os.Getenv("FIT_FOR_FREE_TOKEN"), shouldNotify)
+		adminChatID, parseErr := strconv.ParseInt(os.Getenv("ADMIN_CHAT_ID"), 10, 64)
+		for err := range loginErrors {
+			if parseErr != nil {
+				continue
+			}
+			telegram.Send(tgbotapi.NewMessage(adminChatID, fmt.Sprintf("Inloggen bij FitForFree is mislukt: %s", err)))
 		}
 	}()
 
+	// Setup checker, the scheduler decides which lessons are checked every tick
+	// Lessons are checked at their own venue, lessons stored before venues were kept are checked at VENUE
+	availabilityChecker := checker.New(checker.Config{
+		Lessons:   cache,
+		Notis:     checker.NewNotiStore(db),
+		Format:    messages{},
+		Booker:    checker.NewBooker(client, sealer),
+		Scheduler: checker.NewScheduler(envInt("CHECK_BUDGET", 120), checker.DefaultRushWindows),
+		Venues:    []string{os.Getenv("VENUE")},
+		Interval:  time.Second * 30,
+		Policy:    newPolicy(),
+	})
+	go availabilityChecker.Run(context.Background())
+
+	// Retire notis of lessons that started and purge deleted rows after the retention
+	cleanup := checker.NewCleanup(repositories, messages{}, time.Hour*24*time.Duration(envInt("RETENTION_DAYS", 30)))
+	go cleanup.Run(context.Background(), time.Minute*5)
+
+	// Messages to users are sent from the outbox so they are retried when telegram fails
+	go bot.NewOutboxWorker(repositories.Outbox, telegram).Run(context.Background())
+
+	// Back up the sqlite database, PostgreSQL is backed up with pg_dump
+	if db.Dialector.Name() == "sqlite" {
+		go backups.Run(context.Background(), time.Hour*time.Duration(envInt("BACKUP_HOURS", 24)))
+	}
+
+	// Log how well the lesson cache is doing every hour
+	cacheT := time.NewTicker(time.Hour)
 	go func() {
 		for {
-			available := <-shouldNotify
-
-			// Construct message
-			msg := fmt.Sprintf(
-				`
-				Snel er is plek vrij!
-				
-				Les: %s
-				Datum: %s
-				Start: %s
-				Eind: %s
-				`,
-				available.Lesson.Name,
-				times.FormatTimestamp(available.Lesson.Start, times.DateLayout),
-				times.FormatTimestamp(available.Lesson.Start, times.TimeLayout),
-				times.FormatTimestamp(available.Lesson.Start+available.Lesson.DurationSeconds, times.TimeLayout),
-			)
-
-			// Send msg to user
-			bot.Send(tgbotapi.NewMessage(int64(available.User.ChatID), msg))
+			<-cacheT.C
+			log.Printf("Lesson cache: %s", cache.Stats())
 		}
 	}()
 
@@ -128,6 +275,239 @@ func main() {
 	log.Println("Stopping program")
 }
 
+// databaseDSN returns the driver and dsn of the database to connect to, DATABASE_URL or the sqlite file
+// The driver is DATABASE_DRIVER, or guessed from the scheme of DATABASE_URL when it is not set
+func databaseDSN() (string, string, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = databasePath
+	}
+
+	driver, err := database.DriverFor(os.Getenv("DATABASE_DRIVER"), dsn)
+	return driver, dsn, err
+}
+
+// newBackups returns the backups of db into BACKUP_DIR, keeping the last BACKUP_KEEP
+func newBackups(db *gorm.DB) *database.Backups {
+	dir := os.Getenv("BACKUP_DIR")
+	if dir == "" {
+		dir = backupDir
+	}
+	return database.NewBackups(db, dir, envInt("BACKUP_KEEP", 7))
+}
+
+// newRateLimiter returns the limiter all fitforfree requests go through, configured by the environment
+func newRateLimiter() *fitforfree.RateLimiter {
+	perSecond := 1.0
+	if rate := os.Getenv("FIT_FOR_FREE_RATE"); rate != "" {
+		parsed, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			log.Panicf("ERROR: FIT_FOR_FREE_RATE environment variable must be a number, got %q", rate)
+		}
+		perSecond = parsed
+	}
+
+	limiter, err := fitforfree.NewRateLimiter(perSecond, envInt("FIT_FOR_FREE_BURST", 5))
+	if err != nil {
+		log.Panicf("ERROR: Invalid FIT_FOR_FREE_RATE or FIT_FOR_FREE_BURST environment variable, err: %+v", err)
+	}
+	return limiter
+}
+
+// newPolicy returns the fairness policy configured by the environment, all users are alerted at once by default
+func newPolicy() checker.Policy {
+	stagger := time.Second * time.Duration(envInt("FAIRNESS_STAGGER_SECONDS", 60))
+
+	switch policy := os.Getenv("FAIRNESS"); policy {
+	case "", "all":
+		return checker.AllAtOnce{}
+	case "rotating":
+		return checker.Rotating{Stagger: stagger}
+	case "losers":
+		return checker.FavourLosers{Stagger: stagger}
+	default:
+		log.Panicf("ERROR: FAIRNESS environment variable must be all, rotating or losers, got %q", policy)
+		return nil
+	}
+}
+
+// envInt returns the positive number in the environment variable, or def when it is not set
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Panicf("ERROR: %s environment variable must be a positive number, got %q", name, value)
+	}
+	return parsed
+}
+
+// messages formats the messages the checker sends to users, in their language and timezone
+// Users with the short notification style get a single line
+type messages struct{}
+
+// Available formats the message sent to the user when their lesson has a spot available
+// It has a button to book the lesson if that didn't happen already
+func (messages) Available(available checker.Available) database.OutboxMessage {
+	settings := available.Noti.User.Settings
+	t := func(text string) string {
+		return locale.T(settings.Language, text)
+	}
+
+	var title string
+	switch {
+	case available.Booked:
+		title = t("Er was plek vrij, de les is voor je geboekt!")
+	case available.BookErr != nil:
+		title = fmt.Sprintf(t("Snel er is plek vrij! Automatisch boeken is mislukt: %s."), handlers.BookingErrorReason(settings.Language, available.BookErr))
+	case available.NotFirst:
+		title = t("Snel er is plek vrij! Anderen waren eerder aan de beurt, dus de les is niet automatisch geboekt.")
+	default:
+		title = t("Snel er is plek vrij!")
+	}
+
+	lesson := available.Noti.Lesson
+	loc := settings.Location()
+	var text string
+	if settings.Style() == database.StyleShort {
+		text = shortMessage(settings, title, lesson)
+	} else {
+		text = fmt.Sprintf(
+			t(`
+		%s
+
+		Les: %s
+		Datum: %s
+		Start: %s
+		Eind: %s
+		`),
+			title,
+			lesson.Name,
+			times.FormatTimestampIn(lesson.Start, times.DateLayout, loc),
+			times.FormatTimestampIn(lesson.Start, times.TimeLayout, loc),
+			times.FormatTimestampIn(lesson.Start+lesson.DurationSeconds, times.TimeLayout, loc),
+		)
+
+		if available.Noti.Watch && !available.Booked {
+			text += t("Je krijgt weer bericht als de les vol raakt en er opnieuw plek vrijkomt, verwijder de notificatie als je niet meer wilt.")
+		}
+	}
+
+	message := database.OutboxMessage{
+		ChatID: int64(available.Noti.User.ChatID),
+		Text:   text,
+	}
+	if !available.Booked {
+		message.BookLessonID = lesson.ID
+	}
+	return message
+}
+
+// Changed formats the message sent to the user when their lesson changed upstream
+func (messages) Changed(change checker.Change) database.OutboxMessage {
+	settings := change.Noti.User.Settings
+	t := func(text string) string {
+		return locale.T(settings.Language, text)
+	}
+
+	old := change.Noti.Lesson
+	lesson := change.Lesson
+
+	var title string
+	switch {
+	case change.Has(checker.ChangeCancelled):
+		title = t("Je les is geannuleerd, de notificatie is verwijderd.")
+	case change.Has(checker.ChangeVanished):
+		title = t("Je les staat niet meer in het rooster.")
+	default:
+		title = t("Je les is gewijzigd.")
+	}
+
+	message := database.OutboxMessage{ChatID: int64(change.Noti.User.ChatID)}
+	if settings.Style() == database.StyleShort {
+		message.Text = shortMessage(settings, title, old)
+		return message
+	}
+
+	loc := settings.Location()
+	text := fmt.Sprintf(
+		t(`
+		%s
+
+		Les: %s
+		Datum: %s
+		Start: %s
+		`),
+		title,
+		old.Name,
+		times.FormatTimestampIn(old.Start, times.DateLayout, loc),
+		times.FormatTimestampIn(old.Start, times.TimeLayout, loc),
+	)
+
+	if change.Has(checker.ChangeMoved) {
+		text += fmt.Sprintf(
+			t("Nieuwe tijd: %s %s - %s\n\t\t"),
+			times.FormatTimestampIn(lesson.Start, times.DateLayout, loc),
+			times.FormatTimestampIn(lesson.Start, times.TimeLayout, loc),
+			times.FormatTimestampIn(lesson.Start+lesson.DurationSeconds, times.TimeLayout, loc),
+		)
+	}
+
+	if change.Has(checker.ChangeInstructor) {
+		text += fmt.Sprintf(t("Nieuwe instructeur: %s\n\t\t"), lesson.Instructor)
+	}
+
+	if change.Has(checker.ChangeRoom) {
+		text += fmt.Sprintf(t("Nieuwe zaal: %s\n\t\t"), lesson.RoomName)
+	}
+
+	message.Text = text
+	return message
+}
+
+// Started formats the message sent to the user when their lesson started and the noti is removed
+func (messages) Started(noti database.Noti) database.OutboxMessage {
+	settings := noti.User.Settings
+	t := func(text string) string {
+		return locale.T(settings.Language, text)
+	}
+
+	title := t("Je les is begonnen en er is geen plek vrijgekomen, de notificatie is verwijderd.")
+	if noti.AlertedAt != nil {
+		title = t("Je les is begonnen, de notificatie is verwijderd.")
+	}
+
+	message := database.OutboxMessage{ChatID: int64(noti.User.ChatID)}
+	if settings.Style() == database.StyleShort {
+		message.Text = shortMessage(settings, title, noti.Lesson)
+		return message
+	}
+
+	loc := settings.Location()
+	message.Text = fmt.Sprintf(
+		t(`
+		%s
+
+		Les: %s
+		Datum: %s
+		Start: %s
+		`),
+		title,
+		noti.Lesson.Name,
+		times.FormatTimestampIn(noti.Lesson.Start, times.DateLayout, loc),
+		times.FormatTimestampIn(noti.Lesson.Start, times.TimeLayout, loc),
+	)
+	return message
+}
+
+// shortMessage formats a message of the short notification style, the title with the lesson on a single line
+func shortMessage(settings database.UserSettings, title string, lesson database.Lesson) string {
+	return fmt.Sprintf("%s %s %s", title, lesson.Name, times.FormatTimestampIn(lesson.Start, times.FullLayout, settings.Location()))
+}
+
 // handleStop sends true to the returned channel when sigint or sigterm is received
 func handleStop() chan bool {
 	stop := make(chan bool, 1)
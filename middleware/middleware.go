@@ -1,31 +1,40 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"log"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/laytan/go-fff-notifications-bot/bot"
 	"github.com/laytan/go-fff-notifications-bot/database"
-	"gorm.io/gorm"
 )
 
 // AssureUserExists checks if the update user id exists in our database
 // if it does we set the handlePayload's user to it
 // if it does not we create the user and assign it to the handlePayload
-func AssureUserExists(db *gorm.DB) func(*bot.HandlePayload) {
+func AssureUserExists(users database.UserRepository) func(*bot.HandlePayload) {
 	return func(p *bot.HandlePayload) {
-		if p.Update.Message == nil {
+		var from *tgbotapi.User
+		var chat *tgbotapi.Chat
+		if p.Update.Message != nil {
+			from = p.Update.Message.From
+			chat = p.Update.Message.Chat
+		} else if p.Update.CallbackQuery != nil && p.Update.CallbackQuery.Message != nil {
+			from = p.Update.CallbackQuery.From
+			chat = p.Update.CallbackQuery.Message.Chat
+		} else {
 			return
 		}
 
 		user := database.User{
-			ID:       uint(p.Update.Message.From.ID),
-			Name:     fmt.Sprintf("%s %s", p.Update.Message.From.FirstName, p.Update.Message.From.LastName),
-			Username: p.Update.Message.From.UserName,
-			ChatID:   uint(p.Update.Message.Chat.ID),
+			ID:       uint(from.ID),
+			Name:     fmt.Sprintf("%s %s", from.FirstName, from.LastName),
+			Username: from.UserName,
+			ChatID:   uint(chat.ID),
 		}
 
-		if err := db.FirstOrCreate(&user).Error; err != nil {
+		if err := users.FirstOrCreate(context.Background(), &user); err != nil {
 			log.Printf("ERROR: Error creating/getting user in middleware %+v", err)
 		}
 
@@ -6,7 +6,7 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/laytan/go-fff-notifications-bot/bot"
 	"github.com/laytan/go-fff-notifications-bot/database"
-	"gorm.io/driver/sqlite"
+	"github.com/laytan/go-fff-notifications-bot/database/databasetest"
 	"gorm.io/gorm"
 )
 
@@ -16,6 +16,10 @@ type TestCaseAssureUserExists struct {
 }
 
 func TestAssureUserExists(t *testing.T) {
+	databasetest.Run(t, testAssureUserExists)
+}
+
+func testAssureUserExists(t *testing.T, db *gorm.DB) {
 	cases := []TestCaseAssureUserExists{
 		{
 			user: &database.User{
@@ -43,13 +47,7 @@ func TestAssureUserExists(t *testing.T) {
 		},
 	}
 
-	// Connect to db
-	db, err := gorm.Open(sqlite.Open("../database/test.sqlite"), &gorm.Config{})
-	if err != nil {
-		t.Error(err)
-	}
-	db.AutoMigrate(&database.User{})
-	middleware := AssureUserExists(db)
+	middleware := AssureUserExists(database.NewRepositories(db).Users)
 
 	for _, tCase := range cases {
 		// Clear db and add user
@@ -78,13 +76,11 @@ func TestAssureUserExists(t *testing.T) {
 }
 
 func TestNoMessageAssureUserExists(t *testing.T) {
-	// Connect to db
-	db, err := gorm.Open(sqlite.Open("../database/test.sqlite"), &gorm.Config{})
-	if err != nil {
-		t.Error(err)
-	}
-	db.AutoMigrate(&database.User{})
-	middleware := AssureUserExists(db)
+	databasetest.Run(t, testNoMessageAssureUserExists)
+}
+
+func testNoMessageAssureUserExists(t *testing.T, db *gorm.DB) {
+	middleware := AssureUserExists(database.NewRepositories(db).Users)
 	p := bot.HandlePayload{
 		Update: tgbotapi.Update{},
 	}
@@ -93,3 +89,23 @@ func TestNoMessageAssureUserExists(t *testing.T) {
 		t.Error("Should not have a user here")
 	}
 }
+
+func TestCallbackQueryAssureUserExists(t *testing.T) {
+	databasetest.Run(t, testCallbackQueryAssureUserExists)
+}
+
+func testCallbackQueryAssureUserExists(t *testing.T, db *gorm.DB) {
+	middleware := AssureUserExists(database.NewRepositories(db).Users)
+	p := bot.HandlePayload{
+		Update: tgbotapi.Update{
+			CallbackQuery: &tgbotapi.CallbackQuery{
+				From:    &tgbotapi.User{ID: 12},
+				Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}},
+			},
+		},
+	}
+	middleware(&p)
+	if p.User.ID != 12 {
+		t.Error("Should have the user who pressed the button")
+	}
+}
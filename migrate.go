@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"github.com/laytan/go-fff-notifications-bot/database"
+	"gorm.io/gorm/logger"
+)
+
+// migrate runs the migrate command with the given arguments:
+// migrate [-dry-run] status|up|down [version]
+// up migrates to the version or the latest migration, down reverts to the version or removes everything
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	command := flags.Arg(0)
+	var target uint
+	if version := flags.Arg(1); version != "" {
+		parsed, err := strconv.ParseUint(version, 10, 32)
+		if err != nil {
+			return fmt.Errorf("version must be a number, got %q", version)
+		}
+		target = uint(parsed)
+	}
+
+	// The .env file is optional here, DATABASE_URL can be set in the environment directly
+	godotenv.Load()
+
+	driver, dsn, err := databaseDSN()
+	if err != nil {
+		return err
+	}
+
+	migrator := database.NewMigrator(database.Open(driver, dsn, logger.Discard), database.Migrations)
+	migrator.DryRun = *dryRun
+	migrator.Out = os.Stdout
+
+	// Applied migrations are logged by the migrator
+	switch command {
+	case "status":
+		version, err := migrator.Version()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database is at version %d, the latest migration is %d\n", version, migrator.Latest())
+		return nil
+	case "up":
+		_, err := migrator.Up(target)
+		return err
+	case "down":
+		_, err := migrator.Down(target)
+		return err
+	default:
+		return fmt.Errorf("usage: migrate [-dry-run] status|up|down [version]")
+	}
+}
@@ -9,12 +9,34 @@ const DateLayout = "02-01-2006"
 const TimeLayout = "15:04"
 
 func FormatTimestamp(timestamp uint, layout string) string {
-	t := time.Unix(int64(timestamp), 0)
 	loc, _ := time.LoadLocation("Europe/Amsterdam")
-	t = t.In(loc)
-	return t.Format(layout)
+	return FormatTimestampIn(timestamp, layout, loc)
+}
+
+// FormatTimestampIn formats the timestamp in the timezone
+func FormatTimestampIn(timestamp uint, layout string, loc *time.Location) string {
+	return time.Unix(int64(timestamp), 0).In(loc).Format(layout)
 }
 
 func FromInput(input string, layout string) (time.Time, error) {
 	return time.Parse(layout, input)
 }
+
+// FromInputIn parses the input as a time in the timezone
+func FromInputIn(input string, layout string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(layout, input, loc)
+}
+
+// Day returns the first and last second of the day of the timestamp in Amsterdam
+// A day that starts before the unix epoch starts at 0
+func Day(timestamp uint) (uint, uint) {
+	loc, _ := time.LoadLocation("Europe/Amsterdam")
+	t := time.Unix(int64(timestamp), 0).In(loc)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1).Unix() - 1
+
+	if start.Unix() < 0 {
+		return 0, uint(end)
+	}
+	return uint(start.Unix()), uint(end)
+}
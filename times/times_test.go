@@ -2,6 +2,7 @@ package times
 
 import (
 	"testing"
+	"time"
 )
 
 func TestFromInput(t *testing.T) {
@@ -75,3 +76,40 @@ func TestFormatTimestamp(t *testing.T) {
 		t.Error("Date should be 21:55 29-11-2020")
 	}
 }
+
+func TestFormatTimestampIn(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if FormatTimestampIn(1606683332, TimeLayout, loc) != "20:55" {
+		t.Error("Time should be 20:55 in London")
+	}
+
+	parsed, err := FromInputIn("29-11-2020", DateLayout, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Location() != loc || parsed.Hour() != 0 {
+		t.Errorf("Expected midnight in London, got %s", parsed)
+	}
+}
+
+func TestDay(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Amsterdam")
+	at := time.Date(2030, 3, 31, 18, 0, 0, 0, loc)
+
+	// The clocks go forward on this day, so it is an hour shorter
+	start, end := Day(uint(at.Unix()))
+	if expected := time.Date(2030, 3, 31, 0, 0, 0, 0, loc); start != uint(expected.Unix()) {
+		t.Errorf("Expected the day to start at %s, got %s", expected, time.Unix(int64(start), 0).In(loc))
+	}
+	if expected := time.Date(2030, 3, 31, 23, 59, 59, 0, loc); end != uint(expected.Unix()) {
+		t.Errorf("Expected the day to end at %s, got %s", expected, time.Unix(int64(end), 0).In(loc))
+	}
+
+	if start, _ := Day(10); start != 0 {
+		t.Errorf("Expected the first day to start at 0, got %d", start)
+	}
+}